@@ -0,0 +1,89 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// clientAggregator bounds the cardinality of the client label:
+// client hostnames matching the regex are reduced to its first capture group (e.g., a service name),
+// addresses belonging to the configured networks are replaced with the network.
+type clientAggregator struct {
+	networks      []*net.IPNet
+	hostnameRegex *regexp.Regexp
+}
+
+func newClientAggregator(o CollectorOptions) (*clientAggregator, error) {
+	a := &clientAggregator{}
+	for _, n := range o.Networks {
+		_, network, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", n, err)
+		}
+		a.networks = append(a.networks, network)
+	}
+	if o.HostnameRegex != "" {
+		re, err := regexp.Compile(o.HostnameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hostname regex %q: %w", o.HostnameRegex, err)
+		}
+		a.hostnameRegex = re
+	}
+	return a, nil
+}
+
+// client returns the label value for the connection: the aggregated hostname or address,
+// "local" for Unix-domain socket connections, or "other" for addresses outside the configured networks.
+func (a *clientAggregator) client(addr, hostname string) string {
+	if a.hostnameRegex != nil && hostname != "" {
+		if m := a.hostnameRegex.FindStringSubmatch(hostname); m != nil {
+			if len(m) > 1 {
+				return m[1]
+			}
+			return m[0]
+		}
+	}
+	if addr == "" {
+		return "local"
+	}
+	if len(a.networks) == 0 {
+		return addr
+	}
+	if ip := net.ParseIP(addr); ip != nil {
+		for _, n := range a.networks {
+			if n.Contains(ip) {
+				return n.String()
+			}
+		}
+	}
+	return "other"
+}
+
+func (c *Collector) clientMetrics(ch chan<- prometheus.Metric) {
+	if c.saCurr == nil {
+		return
+	}
+	type clientKey struct {
+		db, user, state, applicationName, client string
+	}
+	counts := map[clientKey]float64{}
+	for _, conn := range c.saCurr.connections {
+		if !conn.IsClientBackend() {
+			continue
+		}
+		k := clientKey{
+			db:              conn.DB.String,
+			user:            conn.User.String,
+			state:           conn.State.String,
+			applicationName: conn.ApplicationName.String,
+			client:          c.clients.client(conn.ClientAddr.String, conn.ClientHostname.String),
+		}
+		counts[k]++
+	}
+	for k, count := range counts {
+		ch <- gauge(dConnectionsByClient, count, k.db, k.user, k.state, k.applicationName, k.client)
+	}
+}
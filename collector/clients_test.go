@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientAggregator(t *testing.T) {
+	a, err := newClientAggregator(CollectorOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.1.5", a.client("10.0.1.5", "api-7f9c4.example.com"))
+	assert.Equal(t, "local", a.client("", ""))
+
+	a, err = newClientAggregator(CollectorOptions{
+		Networks:      []string{"10.0.0.0/16", "fd00::/8"},
+		HostnameRegex: `^([a-z-]+)-[0-9a-f]+\.`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "api", a.client("10.0.1.5", "api-7f9c4.example.com"))
+	assert.Equal(t, "10.0.0.0/16", a.client("10.0.1.5", "db-admin.example.com"))
+	assert.Equal(t, "10.0.0.0/16", a.client("10.0.1.6", ""))
+	assert.Equal(t, "fd00::/8", a.client("fd12::1", ""))
+	assert.Equal(t, "other", a.client("192.168.1.1", ""))
+	assert.Equal(t, "local", a.client("", ""))
+
+	_, err = newClientAggregator(CollectorOptions{Networks: []string{"10.0.0.0"}})
+	assert.Error(t, err)
+
+	_, err = newClientAggregator(CollectorOptions{HostnameRegex: "("})
+	assert.Error(t, err)
+}
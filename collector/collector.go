@@ -15,7 +15,14 @@ import (
 
 const (
 	topQueriesN        = 20
+	topTablesN         = 50
+	topIndexesN        = 100
+	topBloatN          = 50
+	topLockRelationsN  = 50
+	topRelationSizesN  = 50
 	hardQuerySizeLimit = 4096
+
+	perDatabaseVersion = "per_database_version"
 )
 
 var (
@@ -28,6 +35,19 @@ var (
 
 	dConnections = desc("pg_connections", "Number of database connections", "db", "user", "state", "wait_event_type", "query")
 
+	dConnectionsMaxXactAge       = desc("pg_connections_max_xact_age_seconds", "The longest time a transaction of the connections has been open", "db", "user", "query")
+	dConnectionsMaxStateDuration = desc("pg_connections_max_state_duration_seconds", "The longest time the connections have been in the current state, e.g., idle in transaction", "db", "user", "state", "query")
+	dTransactionDuration         = desc("pg_transaction_duration_seconds", "Durations of the finished transactions", "db", "user", "query")
+
+	dConnectionsLimit           = desc("pg_connections_limit", "Number of connections available to non-superusers: max_connections minus the reserved ones")
+	dConnectionsUtilization     = desc("pg_connections_utilization", "Ratio of client connections to the number of connections available to non-superusers")
+	dDbConnectionsLimit         = desc("pg_db_connections_limit", "Maximum number of connections to the database (datconnlimit)", "db")
+	dDbConnectionsUtilization   = desc("pg_db_connections_utilization", "Ratio of client connections to the database to its connection limit", "db")
+	dRoleConnectionsLimit       = desc("pg_role_connections_limit", "Maximum number of connections of the role (rolconnlimit)", "user")
+	dRoleConnectionsUtilization = desc("pg_role_connections_utilization", "Ratio of client connections of the role to its connection limit", "user")
+
+	dConnectionsByClient = desc("pg_connections_by_client", "Number of client connections by application name and client address or hostname", "db", "user", "state", "application_name", "client")
+
 	dLatency = desc("pg_latency_seconds", "Query execution time", "summary")
 
 	dDbQueries = desc("pg_db_queries_per_second", "Number of queries executed in the database per second", "db")
@@ -38,11 +58,129 @@ var (
 
 	dLockAwaitingQueries = desc("pg_lock_awaiting_queries", "Number of queries awaiting a lock", "db", "user", "blocking_query")
 
+	dLockBlockingChainDepth       = desc("pg_lock_blocking_chain_depth", "The longest chain of sessions waiting for each other behind the root blocker", "db", "user", "state", "blocking_query")
+	dLockBlockedSessions          = desc("pg_lock_blocked_sessions", "Number of sessions blocked by the root blocker directly or transitively", "db", "user", "state", "blocking_query")
+	dLockRootBlockerStateDuration = desc("pg_lock_root_blocker_state_duration_seconds", "Time the root blocker has been in its current state", "db", "user", "state", "blocking_query")
+
 	dWalReceiverStatus = desc("pg_wal_receiver_status", "WAL receiver status: 1 if the receiver is connected, otherwise 0", "sender_host", "sender_port")
 	dWalReplayPaused   = desc("pg_wal_replay_paused", "Whether WAL replay paused or not")
 	dWalCurrentLsn     = desc("pg_wal_current_lsn", "Current WAL sequence number")
 	dWalReceiveLsn     = desc("pg_wal_receive_lsn", "WAL sequence number that has been received and synced to disk by streaming replication")
 	dWalReplyLsn       = desc("pg_wal_reply_lsn", "WAL sequence number that has been replayed during recovery")
+	dWalReplayLag      = desc("pg_wal_replay_lag_seconds", "Time elapsed since the last transaction replayed during recovery was committed on the primary, 0 if all the received WAL has been replayed")
+
+	dWalReceiverInfo               = desc("pg_wal_receiver_info", "WAL receiver process status and the replication slot it uses", "status", "slot_name")
+	dWalReceiverTimeline           = desc("pg_wal_receiver_timeline", "Timeline number of the last WAL location received and flushed to disk")
+	dWalReceiverLastMsgSendTime    = desc("pg_wal_receiver_last_msg_send_timestamp_seconds", "Send time of the last message received from the WAL sender")
+	dWalReceiverLastMsgReceiptTime = desc("pg_wal_receiver_last_msg_receipt_timestamp_seconds", "Receipt time of the last message received from the WAL sender")
+	dWalReceiverLatestEndLsn       = desc("pg_wal_receiver_latest_end_lsn", "Last WAL location reported to the WAL sender")
+	dWalReceiverLatestEndTime      = desc("pg_wal_receiver_latest_end_timestamp_seconds", "Time of the last WAL location reported to the WAL sender")
+
+	dReplicationStandbyStatus     = desc("pg_replication_standby_status", "Standby connected to the WAL sender: 1 with the current state and synchronization state", "application_name", "client_addr", "pid", "state", "sync_state")
+	dReplicationStandbyLagBytes   = desc("pg_replication_standby_lag_bytes", "How far the WAL sent to the standby, written, flushed and replayed by it is behind the server", "application_name", "client_addr", "pid", "stage")
+	dReplicationStandbyLagSeconds = desc("pg_replication_standby_lag_seconds", "Time elapsed between flushing recent WAL locally and receiving notification that the standby has written, flushed and replayed it", "application_name", "client_addr", "pid", "stage")
+
+	dReplicationSlotActive            = desc("pg_replication_slot_active", "Whether the replication slot is currently being used", "slot_name", "slot_type", "plugin", "database")
+	dReplicationSlotRetainedWal       = desc("pg_replication_slot_retained_wal_bytes", "Amount of WAL retained by the replication slot (the current WAL position minus restart_lsn)", "slot_name")
+	dReplicationSlotConfirmedFlushLag = desc("pg_replication_slot_confirmed_flush_lag_bytes", "How far the position confirmed by the consumer of the logical slot is behind the current WAL position", "slot_name")
+	dReplicationSlotWalStatus         = desc("pg_replication_slot_wal_status", "Availability of WAL files claimed by the slot: reserved, extended, unreserved or lost", "slot_name", "wal_status")
+	dReplicationSlotSafeWalSize       = desc("pg_replication_slot_safe_wal_size_bytes", "Amount of WAL that can be written before the slot is in danger of getting in the lost state", "slot_name")
+
+	dArchiverArchived         = desc("pg_archiver_archived_total", "Number of WAL files that have been successfully archived")
+	dArchiverFailed           = desc("pg_archiver_failed_total", "Number of failed attempts for archiving WAL files")
+	dArchiverLastArchivedTime = desc("pg_archiver_last_archived_timestamp_seconds", "Time of the last successful archive operation")
+	dArchiverLastFailedTime   = desc("pg_archiver_last_failed_timestamp_seconds", "Time of the last failed archival operation")
+	dArchiverLastArchivedLsn  = desc("pg_archiver_last_archived_wal_lsn", "Starting WAL position of the last successfully archived WAL file")
+	dArchiverLastFailedLsn    = desc("pg_archiver_last_failed_wal_lsn", "Starting WAL position of the WAL file of the last failed archival operation")
+	dArchiverReadyFiles       = desc("pg_archiver_ready_files", "Number of WAL files waiting to be archived")
+	dArchiverLag              = desc("pg_archiver_lag_seconds", "Age of the oldest WAL file waiting to be archived, 0 if there are no such files")
+
+	dSubscriptionWorkers      = desc("pg_subscription_workers", "Number of running apply and table synchronization workers of the subscription", "subscription", "type")
+	dSubscriptionReceivedLsn  = desc("pg_subscription_received_lsn", "Last WAL position received by the subscription apply worker", "subscription")
+	dSubscriptionLatestEndLsn = desc("pg_subscription_latest_end_lsn", "Last WAL position reported to the publisher by the subscription apply worker", "subscription")
+	dSubscriptionApplyLag     = desc("pg_subscription_apply_lag_seconds", "Time elapsed since the subscription apply worker last reported its position to the publisher", "subscription")
+	dSubscriptionApplyErrors  = desc("pg_subscription_apply_errors_total", "Number of errors occurred while applying changes of the subscription", "subscription")
+	dSubscriptionSyncErrors   = desc("pg_subscription_sync_errors_total", "Number of errors occurred during the initial table synchronization of the subscription", "subscription")
+	dPublicationTables        = desc("pg_publication_tables", "Number of tables published by the publication", "db", "publication", "all_tables")
+
+	dDbTransactions       = desc("pg_db_transactions_total", "Number of transactions in the database that have been committed or rolled back", "db", "status")
+	dDbBlocksRead         = desc("pg_db_blocks_read_total", "Number of disk blocks read in the database", "db")
+	dDbBlocksHit          = desc("pg_db_blocks_hit_total", "Number of times disk blocks were found already in the buffer cache", "db")
+	dDbTuples             = desc("pg_db_tuples_total", "Number of rows returned, fetched, inserted, updated or deleted by queries in the database", "db", "operation")
+	dDbTempFiles          = desc("pg_db_temp_files_total", "Number of temporary files created by queries in the database", "db")
+	dDbTempBytes          = desc("pg_db_temp_bytes_total", "Total amount of data written to temporary files by queries in the database", "db")
+	dDbDeadlocks          = desc("pg_db_deadlocks_total", "Number of deadlocks detected in the database", "db")
+	dDbConflicts          = desc("pg_db_conflicts_total", "Number of queries canceled due to conflicts with recovery in the database", "db")
+	dDbChecksumFailures   = desc("pg_db_checksum_failures_total", "Number of data page checksum failures detected in the database", "db")
+	dDbSessionTime        = desc("pg_db_session_time_seconds_total", "Time spent by sessions in the database in total, executing queries and idling in transaction", "db", "state")
+	dDbSessions           = desc("pg_db_sessions_total", "Number of sessions established to the database", "db")
+	dDbSessionsTerminated = desc("pg_db_sessions_terminated_total", "Number of sessions to the database terminated by lost client connection, fatal errors or operator intervention", "db", "reason")
+
+	dTableScans        = desc("pg_table_scans_total", "Number of sequential and index scans initiated on the table", "db", "schema", "table", "type")
+	dTableRowsRead     = desc("pg_table_rows_read_total", "Number of live rows read by sequential scans and fetched by index scans", "db", "schema", "table", "type")
+	dTableRowsModified = desc("pg_table_rows_modified_total", "Number of rows inserted, updated, HOT updated and deleted", "db", "schema", "table", "operation")
+	dTableRows         = desc("pg_table_rows", "Estimated number of live and dead rows", "db", "schema", "table", "state")
+	dTableLastVacuum   = desc("pg_table_last_vacuum_timestamp_seconds", "Last time the table was vacuumed manually or by the autovacuum daemon", "db", "schema", "table", "type")
+	dTableLastAnalyze  = desc("pg_table_last_analyze_timestamp_seconds", "Last time the table was analyzed manually or by the autovacuum daemon", "db", "schema", "table", "type")
+	dTableVacuums      = desc("pg_table_vacuums_total", "Number of times the table has been vacuumed manually or by the autovacuum daemon", "db", "schema", "table", "type")
+	dTableAnalyzes     = desc("pg_table_analyzes_total", "Number of times the table has been analyzed manually or by the autovacuum daemon", "db", "schema", "table", "type")
+	dTableBlocksRead   = desc("pg_table_blocks_read_total", "Number of disk blocks read from the table, its indexes and TOAST", "db", "schema", "table", "kind")
+	dTableBlocksHit    = desc("pg_table_blocks_hit_total", "Number of buffer hits in the table, its indexes and TOAST", "db", "schema", "table", "kind")
+
+	dIndexScans       = desc("pg_index_scans_total", "Number of index scans initiated on the index", "db", "schema", "table", "index")
+	dIndexRowsRead    = desc("pg_index_rows_read_total", "Number of index entries returned by scans on the index", "db", "schema", "table", "index")
+	dIndexRowsFetched = desc("pg_index_rows_fetched_total", "Number of live table rows fetched by simple index scans using the index", "db", "schema", "table", "index")
+	dIndexSize        = desc("pg_index_size_bytes", "Disk space used by the index", "db", "schema", "table", "index")
+	dIndexUnused      = desc("pg_index_unused", "Whether the index hasn't been scanned since the statistics reset and doesn't enforce a constraint", "db", "schema", "table", "index")
+	dIndexInvalid     = desc("pg_index_invalid", "Whether the index is invalid (e.g., after a failed CREATE INDEX CONCURRENTLY)", "db", "schema", "table", "index")
+	dIndexRedundant   = desc("pg_index_redundant", "The index duplicates or is overlapped by another index of the same table", "db", "schema", "table", "index", "reason", "covered_by")
+
+	dTableBloatBytes = desc("pg_table_bloat_bytes", "Estimated amount of free and dead space in the table", "db", "schema", "table")
+	dTableBloatRatio = desc("pg_table_bloat_ratio", "Estimated share of free and dead space in the table", "db", "schema", "table")
+	dIndexBloatBytes = desc("pg_index_bloat_bytes", "Estimated amount of bloat in the B-tree index", "db", "schema", "table", "index")
+	dIndexBloatRatio = desc("pg_index_bloat_ratio", "Estimated share of bloat in the B-tree index", "db", "schema", "table", "index")
+
+	dDbSize         = desc("pg_db_size_bytes", "Disk space used by the database", "db")
+	dTablespaceSize = desc("pg_tablespace_size_bytes", "Disk space used by the tablespace", "tablespace")
+	dTableSize      = desc("pg_table_size_bytes", "Disk space used by the table heap, its indexes and TOAST", "db", "schema", "table", "kind")
+
+	dCheckpoints             = desc("pg_checkpoints_total", "Number of scheduled and requested checkpoints that have been performed", "type")
+	dCheckpointTime          = desc("pg_checkpoint_time_seconds_total", "Time spent in the portion of checkpoint processing where files are written or synchronized to disk", "stage")
+	dBuffersWritten          = desc("pg_buffers_written_total", "Number of buffers written by the checkpointer, the background writer and backends", "by")
+	dBgwriterMaxwrittenClean = desc("pg_bgwriter_maxwritten_clean_total", "Number of times the background writer stopped a cleaning scan because it had written too many buffers")
+	dBuffersBackendFsync     = desc("pg_buffers_backend_fsync_total", "Number of times a backend had to execute its own fsync call")
+	dBuffersAllocated        = desc("pg_buffers_allocated_total", "Number of buffers allocated")
+
+	dWalRecords     = desc("pg_wal_records_total", "Number of WAL records generated")
+	dWalFpi         = desc("pg_wal_fpi_total", "Number of WAL full page images generated")
+	dWalBytes       = desc("pg_wal_bytes_total", "Amount of WAL generated in bytes")
+	dWalBuffersFull = desc("pg_wal_buffers_full_total", "Number of times WAL data was written to disk because WAL buffers became full")
+
+	dIOOperations = desc("pg_io_operations_total", "Number of IO operations by backend type, target object and context", "backend_type", "object", "context", "operation")
+
+	dProgressBlocksTotal = desc("pg_progress_blocks_total", "Total number of blocks to be processed by the running VACUUM, ANALYZE, CREATE INDEX, CLUSTER or VACUUM FULL", "pid", "db", "user", "command", "relation", "phase")
+	dProgressBlocksDone  = desc("pg_progress_blocks_done", "Number of blocks already processed by the running VACUUM, ANALYZE, CREATE INDEX, CLUSTER or VACUUM FULL", "pid", "db", "user", "command", "relation", "phase")
+	dProgressBytesTotal  = desc("pg_progress_bytes_total", "Total number of bytes to be processed by the running COPY or base backup", "pid", "db", "user", "command", "relation", "phase")
+	dProgressBytesDone   = desc("pg_progress_bytes_done", "Number of bytes already processed by the running COPY or base backup", "pid", "db", "user", "command", "relation", "phase")
+	dProgressDuration    = desc("pg_progress_duration_seconds", "Time elapsed since the operation started", "pid", "db", "user", "command", "relation", "phase")
+
+	dDbXidAge                 = desc("pg_db_xid_age", "Age of the oldest unfrozen transaction ID in the database (age(datfrozenxid))", "db")
+	dDbMxidAge                = desc("pg_db_mxid_age", "Age of the oldest unfrozen multixact ID in the database (mxid_age(datminmxid))", "db")
+	dDbXidFreezeRemaining     = desc("pg_db_xid_autovacuum_freeze_remaining", "Number of transactions left until autovacuum_freeze_max_age forces an anti-wraparound vacuum in the database", "db")
+	dDbMxidFreezeRemaining    = desc("pg_db_mxid_autovacuum_freeze_remaining", "Number of multixacts left until autovacuum_multixact_freeze_max_age forces an anti-wraparound vacuum in the database", "db")
+	dDbXidWraparoundRemaining = desc("pg_db_xid_wraparound_remaining", "Number of transactions left until the transaction ID wraparound", "db")
+	dDbOldestRelationXidAge   = desc("pg_db_oldest_relation_xid_age", "Age of the relation with the oldest unfrozen transaction ID (age(relfrozenxid)) in the database", "db", "relation")
+	dDbOldestRelationMxidAge  = desc("pg_db_oldest_relation_mxid_age", "Age of the oldest unfrozen multixact ID (mxid_age(relminmxid)) of the relation with the oldest unfrozen transaction ID", "db", "relation")
+
+	dXminHorizonAge = desc("pg_xmin_horizon_age", "Age of the oldest xmin held back by each kind of holder: backends, prepared transactions, replication slots and standbys with hot_standby_feedback", "holder", "db", "user", "name")
+
+	dPreparedXacts          = desc("pg_prepared_xacts", "Number of transactions prepared for two-phase commit", "db", "owner")
+	dPreparedXactsMaxAge    = desc("pg_prepared_xacts_max_age_seconds", "Time elapsed since the oldest transaction was prepared for two-phase commit", "db", "owner")
+	dPreparedXactsMaxXidAge = desc("pg_prepared_xacts_max_xid_age", "Age of the transaction ID of the oldest prepared transaction", "db", "owner")
+
+	dLocks        = desc("pg_locks", "Number of locks held or awaited by lock type, mode and relation", "db", "relation", "locktype", "mode")
+	dLocksWaiting = desc("pg_locks_waiting", "Number of locks not granted yet by relation", "db", "relation")
+	dLocksMaxWait = desc("pg_locks_max_wait_seconds", "The longest time a lock on the relation has been waited for", "db", "relation")
 )
 
 type QueryKey struct {
@@ -71,12 +209,17 @@ type Collector struct {
 	ctx           context.Context
 	ctxCancelFunc context.CancelFunc
 
+	options        Options
 	scrapeInterval time.Duration
 	collectTimeout time.Duration
 
+	dsn         string
 	db          *sql.DB
 	origVersion string
 
+	dbs     map[string]*sql.DB
+	dbsLock sync.Mutex
+
 	statsDumpInterval time.Duration
 	ssCurr            *ssSnapshot
 	ssPrev            *ssSnapshot
@@ -84,88 +227,205 @@ type Collector struct {
 	saPrev            *saSnapshot
 	settings          []Setting
 	replicationStatus *replicationStatus
+	statDatabase      []dbStat
+	tables            []tableStat
+	indexes           []indexStat
+	bloat             []bloatStat
+	bgwriter          *bgwriterStat
+	wal               *walStat
+	statIO            []ioStat
+	progress          []progress
+	wraparound        []wraparoundStat
+	xminHolders       []xminHolder
+	preparedXacts     []preparedXactStat
+	connectionLimits  *connectionLimits
+	clients           *clientAggregator
+	xactDurations     *xactDurations
+	locks             []lockStat
+	awaitedLocks      map[int]AwaitedLock
+	sizes             *sizes
+	publications      []publicationStatus
 	scrapeErrors      map[string]bool
+	periodicErrors    map[string]error
 
 	lock   sync.RWMutex
 	logger logger.Logger
 }
 
-func New(dsn string, scrapeInterval, collectTimeout time.Duration, logger logger.Logger) (*Collector, error) {
+func New(dsn string, options Options, logger logger.Logger) (*Collector, error) {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 	c := &Collector{
 		ctx:            ctx,
 		logger:         logger,
 		ctxCancelFunc:  cancelFunc,
 		scrapeErrors:   map[string]bool{},
-		scrapeInterval: scrapeInterval,
-		collectTimeout: collectTimeout,
+		periodicErrors: map[string]error{},
+		dsn:            dsn,
+		dbs:            map[string]*sql.DB{},
+		options:        options,
+		scrapeInterval: options.ScrapeInterval,
+		collectTimeout: options.CollectTimeout,
+		xactDurations:  newXactDurations(),
 	}
 	var err error
+	if c.clients, err = newClientAggregator(options.collector(collectorClients)); err != nil {
+		cancelFunc()
+		return nil, err
+	}
 	c.db, err = sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
 	c.db.SetMaxOpenConns(1)
-	pingCtx, pingCancelFunc := context.WithTimeout(ctx, collectTimeout)
+	pingCtx, pingCancelFunc := context.WithTimeout(ctx, c.collectTimeout)
 	defer pingCancelFunc()
 	if err := c.db.PingContext(pingCtx); err != nil {
 		c.logger.Warning("probe failed:", err)
 	}
+	c.runPeriodically(c.scrapeInterval, c.snapshot)
+	c.runPeriodically(c.scrapeInterval, c.updatePerDatabase)
+	if o := options.collector(collectorBloat); o.enabled() {
+		c.runPeriodically(o.Interval, c.updateBloat)
+	}
+	if o := options.collector(collectorSizes); o.enabled() {
+		c.runPeriodically(o.Interval, c.updateSizes)
+	}
+	return c, nil
+}
+
+func (c *Collector) runPeriodically(interval time.Duration, f func()) {
 	go func() {
-		ticker := time.NewTicker(scrapeInterval)
-		c.snapshot()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		f()
 		for {
 			select {
 			case <-ticker.C:
-				c.snapshot()
-			case <-ctx.Done():
-				c.logger.Info("stopping pg collector")
+				f()
+			case <-c.ctx.Done():
 				return
 			}
 		}
 	}()
-	return c, nil
 }
 
-func (c *Collector) snapshot() {
+// snapshotTimeout bounds a snapshot so that it finishes before the next one starts.
+func (c *Collector) snapshotTimeout() time.Duration {
 	timeout := c.scrapeInterval - time.Second
 	if timeout <= 0 {
 		timeout = time.Second
 	}
+	return timeout
+}
 
-	ctx, cancelFunc := context.WithTimeout(c.ctx, timeout)
+func (c *Collector) getVersion(ctx context.Context) (string, semver.Version, error) {
+	var rawVersion string
+	err := c.db.QueryRowContext(ctx, `SELECT setting FROM pg_settings WHERE name='server_version'`).Scan(&rawVersion)
+	if err != nil {
+		return "", semver.Version{}, err
+	}
+	return parsePgVersion(rawVersion)
+}
+
+// snapshot queries the server-wide views through the main connection.
+// The baseline query and connection metrics go first, so they don't depend on the other collectors.
+func (c *Collector) snapshot() {
+	ctx, cancelFunc := context.WithTimeout(c.ctx, c.snapshotTimeout())
 	defer cancelFunc()
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	c.scrapeErrors = map[string]bool{}
 
-	c.origVersion = ""
 	var version semver.Version
-	var rawVersion string
-	err := c.db.QueryRowContext(ctx, `SELECT setting FROM pg_settings WHERE name='server_version'`).Scan(&rawVersion)
+	var err error
+	c.origVersion, version, err = c.getVersion(ctx)
 	if err != nil {
 		c.logger.Warning(err)
 		c.scrapeErrors[err.Error()] = true
 		return
 	}
-	c.origVersion, version, err = parsePgVersion(rawVersion)
-	if err != nil {
+
+	if c.options.collector(collectorSettings).enabled() {
+		if c.settings, err = c.getSettings(ctx); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		}
+	}
+
+	if err = c.snapshotQueries(ctx, version); err != nil {
 		c.logger.Warning(err)
 		c.scrapeErrors[err.Error()] = true
-		return
 	}
 
-	if c.settings, err = c.getSettings(ctx); err != nil {
-		c.scrapeErrors[err.Error()] = true
-		c.logger.Warning(err)
+	if c.options.collector(collectorReplication).enabled() {
+		if c.replicationStatus, err = c.getReplicationStatus(ctx, version); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		} else {
+			for _, err := range c.replicationStatus.errors {
+				c.scrapeErrors[err.Error()] = true
+				c.logger.Warning(err)
+			}
+		}
 	}
 
-	if c.replicationStatus, err = c.getReplicationStatus(ctx, version); err != nil {
-		c.scrapeErrors[err.Error()] = true
-		c.logger.Warning(err)
+	if c.options.collector(collectorStatDatabase).enabled() {
+		if c.statDatabase, err = c.getStatDatabase(ctx, version); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		}
+	}
+
+	if c.options.collector(collectorBgwriter).enabled() {
+		if c.bgwriter, err = c.getBgwriterStat(ctx, version); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		}
+	}
+
+	if c.options.collector(collectorWal).enabled() {
+		if c.wal, err = c.getWalStat(ctx, version); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		}
+	}
+
+	if c.options.collector(collectorStatIO).enabled() {
+		if c.statIO, err = c.getStatIO(ctx, version); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		}
+	}
+
+	// `backend_xmin` and the `xmin` of replication slots have been introduced in 9.4
+	if c.options.collector(collectorXminHorizon).enabled() && semver.MustParseRange(">=9.4.0")(version) {
+		if c.xminHolders, err = c.getXminHolders(ctx); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		}
 	}
 
+	if c.options.collector(collectorPreparedXacts).enabled() {
+		if c.preparedXacts, err = c.getPreparedXacts(ctx); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		}
+	}
+
+	if c.options.collector(collectorConnectionLimits).enabled() {
+		if c.connectionLimits, err = c.getConnectionLimits(ctx, version); err != nil {
+			c.scrapeErrors[err.Error()] = true
+			c.logger.Warning(err)
+		}
+	}
+}
+
+// snapshotQueries queries pg_stat_statements and pg_stat_activity.
+// pg_stat_activity is skipped if pg_stat_statements fails, the same as when these were the last queries of the snapshot.
+func (c *Collector) snapshotQueries(ctx context.Context, version semver.Version) error {
+	var err error
+
 	querySizeLimit := 0
 	for _, s := range c.settings {
 		if s.Name == "track_activity_query_size" {
@@ -186,22 +446,80 @@ func (c *Collector) snapshot() {
 
 	c.ssPrev = c.ssCurr
 	c.saPrev = c.saCurr
-	prevStatements := map[statementId]ssRow{}
-	if c.ssPrev != nil {
-		prevStatements = c.ssPrev.rows
+	if c.options.collector(collectorQueries).enabled() {
+		prevStatements := map[statementId]ssRow{}
+		if c.ssPrev != nil {
+			prevStatements = c.ssPrev.rows
+		}
+		if c.ssCurr, err = c.getStatStatements(ctx, version, querySizeLimit, prevStatements); err != nil {
+			return err
+		}
 	}
-	c.ssCurr, err = c.getStatStatements(ctx, version, querySizeLimit, prevStatements)
-	if err != nil {
-		c.logger.Warning(err)
-		c.scrapeErrors[err.Error()] = true
-		return
+	if c.saCurr, err = c.getPgStatActivity(ctx, version, querySizeLimit); err != nil {
+		return err
 	}
-	c.saCurr, err = c.getPgStatActivity(ctx, version, querySizeLimit)
+	c.xactDurations.update(c.saPrev, c.saCurr)
+	return nil
+}
+
+// updatePerDatabase runs the collectors querying every database in a separate goroutine with its own timeout,
+// so that many or slow databases neither delay the snapshot nor block Collect.
+// The lock is held only to store the results. Per-database errors are logged by forEachDatabase.
+func (c *Collector) updatePerDatabase() {
+	ctx, cancelFunc := context.WithTimeout(c.ctx, c.snapshotTimeout())
+	defer cancelFunc()
+
+	_, version, err := c.getVersion(ctx)
 	if err != nil {
 		c.logger.Warning(err)
-		c.scrapeErrors[err.Error()] = true
+		c.lock.Lock()
+		c.periodicErrors[perDatabaseVersion] = err
+		c.lock.Unlock()
 		return
 	}
+
+	errs := map[string]error{perDatabaseVersion: nil}
+	var (
+		progress     []progress
+		wraparound   []wraparoundStat
+		locks        []lockStat
+		awaitedLocks map[int]AwaitedLock
+		tables       []tableStat
+		indexes      []indexStat
+		publications []publicationStatus
+	)
+	if c.options.collector(collectorProgress).enabled() {
+		progress, errs[collectorProgress] = c.getProgress(ctx, version)
+	}
+	if c.options.collector(collectorWraparound).enabled() {
+		wraparound, errs[collectorWraparound] = c.getWraparound(ctx, version)
+	}
+	if o := c.options.collector(collectorLocks); o.enabled() {
+		locks, awaitedLocks, errs[collectorLocks] = c.getLocks(ctx, version, o.TopN)
+	}
+	if o := c.options.collector(collectorTables); o.enabled() {
+		tables, errs[collectorTables] = c.getTableStats(ctx, o.TopN)
+	}
+	if o := c.options.collector(collectorIndexes); o.enabled() {
+		indexes, errs[collectorIndexes] = c.getIndexStats(ctx, o.TopN)
+	}
+	// logical replication has been introduced in 10
+	if c.options.collector(collectorReplication).enabled() && c.options.collector(collectorLogicalReplication).enabled() &&
+		semver.MustParseRange(">=10.0.0")(version) {
+		publications, errs[collectorLogicalReplication] = c.getPublications(ctx)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.progress = progress
+	c.wraparound = wraparound
+	c.locks, c.awaitedLocks = locks, awaitedLocks
+	c.tables = tables
+	c.indexes = indexes
+	c.publications = publications
+	for name, err := range errs {
+		c.periodicErrors[name] = err
+	}
 }
 
 func (c *Collector) summaries() (map[QueryKey]*QuerySummary, time.Duration) {
@@ -257,8 +575,9 @@ func (c *Collector) connectionMetrics(ch chan<- prometheus.Metric) {
 	for pid, conn := range c.saCurr.connections {
 		queryKey := conn.QueryKey()
 		byPid[pid] = queryKey
-		if conn.BlockingPid.Int32 > 0 {
-			awaitingQueriesByBlockingPid[int(conn.BlockingPid.Int32)]++
+		// a session waiting for several others is counted only under the first one, as (pg_blocking_pids(pid))[1] did
+		if len(conn.BlockingPids) > 0 && conn.BlockingPids[0] > 0 {
+			awaitingQueriesByBlockingPid[int(conn.BlockingPids[0])]++
 		}
 		key := ConnectionKey{
 			QueryKey:      queryKey,
@@ -283,6 +602,80 @@ func (c *Collector) connectionMetrics(ch chan<- prometheus.Metric) {
 	for blockingQuery, awaitingQueries := range awaitingQueriesByBlockingQuery {
 		ch <- gauge(dLockAwaitingQueries, awaitingQueries, blockingQuery.DB, blockingQuery.User, blockingQuery.Query)
 	}
+
+	c.blockingChainMetrics(ch, newWaitForGraph(c.saCurr.connections))
+	c.transactionMetrics(ch)
+}
+
+func (c *Collector) transactionMetrics(ch chan<- prometheus.Metric) {
+	type stateKey struct {
+		QueryKey
+		state string
+	}
+	maxXactAge := map[QueryKey]float64{}
+	maxStateDuration := map[stateKey]float64{}
+	for _, conn := range c.saCurr.connections {
+		queryKey := conn.QueryKey()
+		if conn.StateChange.Valid {
+			k := stateKey{QueryKey: queryKey, state: conn.State.String}
+			if d := c.saCurr.ts.Sub(conn.StateChange.Time).Seconds(); d > maxStateDuration[k] {
+				maxStateDuration[k] = d
+			}
+		}
+		if !conn.XactStart.Valid {
+			continue
+		}
+		age := c.saCurr.ts.Sub(conn.XactStart.Time).Seconds()
+		if age > maxXactAge[queryKey] {
+			maxXactAge[queryKey] = age
+		}
+	}
+	for k, age := range maxXactAge {
+		ch <- gauge(dConnectionsMaxXactAge, age, k.DB, k.User, k.Query)
+	}
+	for k, d := range maxStateDuration {
+		ch <- gauge(dConnectionsMaxStateDuration, d, k.DB, k.User, k.state, k.Query)
+	}
+	c.xactDurations.metrics(ch)
+}
+
+func (c *Collector) blockingChainMetrics(ch chan<- prometheus.Metric, graph *waitForGraph) {
+	type rootKey struct {
+		QueryKey
+		state string
+	}
+	type rootStats struct {
+		depth         float64
+		blocked       float64
+		stateDuration float64
+	}
+	roots := map[rootKey]*rootStats{}
+	for _, chain := range graph.chains() {
+		conn, ok := c.saCurr.connections[chain.rootPid]
+		if !ok {
+			continue
+		}
+		k := rootKey{QueryKey: conn.QueryKey(), state: conn.State.String}
+		r := roots[k]
+		if r == nil {
+			r = &rootStats{}
+			roots[k] = r
+		}
+		if d := float64(chain.depth); d > r.depth {
+			r.depth = d
+		}
+		r.blocked += float64(chain.blocked)
+		if conn.StateChange.Valid {
+			if d := c.saCurr.ts.Sub(conn.StateChange.Time).Seconds(); d > r.stateDuration {
+				r.stateDuration = d
+			}
+		}
+	}
+	for k, r := range roots {
+		ch <- gauge(dLockBlockingChainDepth, r.depth, k.DB, k.User, k.state, k.Query)
+		ch <- gauge(dLockBlockedSessions, r.blocked, k.DB, k.User, k.state, k.Query)
+		ch <- gauge(dLockRootBlockerStateDuration, r.stateDuration, k.DB, k.User, k.state, k.Query)
+	}
 }
 
 func (c *Collector) queryMetrics(ch chan<- prometheus.Metric) {
@@ -306,7 +699,7 @@ func (c *Collector) queryMetrics(ch chan<- prometheus.Metric) {
 		ch <- gauge(dDbQueries, queries/interval.Seconds(), db)
 	}
 
-	for k, summary := range top(summaries, topQueriesN) {
+	for k, summary := range top(summaries, c.options.collector(collectorQueries).TopN) {
 		ch <- gauge(dTopQueryCalls, summary.Queries/interval.Seconds(), k.DB, k.User, k.Query)
 		ch <- gauge(dTopQueryTime, summary.TotalTime/interval.Seconds(), k.DB, k.User, k.Query)
 		ch <- gauge(dTopQueryIOTime, summary.IOTime/interval.Seconds(), k.DB, k.User, k.Query)
@@ -314,7 +707,9 @@ func (c *Collector) queryMetrics(ch chan<- prometheus.Metric) {
 }
 
 func (c *Collector) Close() error {
+	c.logger.Info("stopping pg collector")
 	c.ctxCancelFunc()
+	c.closeDatabases()
 	return c.db.Close()
 }
 
@@ -337,8 +732,17 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
-	if len(c.scrapeErrors) > 0 {
-		for e := range c.scrapeErrors {
+	scrapeErrors := map[string]bool{}
+	for e := range c.scrapeErrors {
+		scrapeErrors[e] = true
+	}
+	for _, err := range c.periodicErrors {
+		if err != nil {
+			scrapeErrors[err.Error()] = true
+		}
+	}
+	if len(scrapeErrors) > 0 {
+		for e := range scrapeErrors {
 			ch <- gauge(dScrapeError, 1, "", e)
 		}
 	} else {
@@ -346,36 +750,71 @@ func (c *Collector) Collect(ch chan<- prometheus.Metric) {
 	}
 
 	c.connectionMetrics(ch)
-	c.queryMetrics(ch)
+	if c.options.collector(collectorQueries).enabled() {
+		c.queryMetrics(ch)
+	}
 	for _, s := range c.settings {
 		ch <- gauge(dSettings, s.Value, s.Name, s.Unit)
 	}
+	c.statDatabaseMetrics(ch)
+	c.bgwriterMetrics(ch)
+	c.walMetrics(ch)
+	c.statIOMetrics(ch)
+	c.progressMetrics(ch)
+	c.wraparoundMetrics(ch)
+	if c.options.collector(collectorXminHorizon).enabled() {
+		c.xminHorizonMetrics(ch)
+	}
+	c.preparedXactMetrics(ch)
+	c.connectionLimitMetrics(ch)
+	if c.options.collector(collectorClients).enabled() {
+		c.clientMetrics(ch)
+	}
+	c.lockMetrics(ch)
+	c.tableMetrics(ch)
+	c.indexMetrics(ch)
+	c.bloatMetrics(ch)
+	c.sizeMetrics(ch)
+	c.replicationMetrics(ch)
+}
 
-	if c.replicationStatus != nil {
-		rs := c.replicationStatus
-		if rs.isInRecovery {
-			if rs.receiveLsn.Valid {
-				ch <- counter(dWalReceiveLsn, float64(rs.receiveLsn.Int64))
-			}
-			if rs.replyLsn.Valid {
-				ch <- counter(dWalReplyLsn, float64(rs.replyLsn.Int64))
-			}
-			isReplayPaused := 0.0
-			if rs.isReplayPaused {
-				isReplayPaused = 1.0
-			}
-			ch <- gauge(dWalReplayPaused, isReplayPaused)
-			host, port, err := rs.primaryHostPort()
-			if err != nil {
-				c.logger.Warning(err)
-			}
-			ch <- gauge(dWalReceiverStatus, float64(rs.walReceiverStatus), host, port)
-		} else {
-			if rs.currentLsn.Valid {
-				ch <- counter(dWalCurrentLsn, float64(rs.currentLsn.Int64))
-			}
+func (c *Collector) replicationMetrics(ch chan<- prometheus.Metric) {
+	rs := c.replicationStatus
+	if rs == nil {
+		return
+	}
+	if rs.isInRecovery {
+		if rs.receiveLsn.Valid {
+			ch <- counter(dWalReceiveLsn, float64(rs.receiveLsn.Int64))
+		}
+		if rs.replyLsn.Valid {
+			ch <- counter(dWalReplyLsn, float64(rs.replyLsn.Int64))
+		}
+		isReplayPaused := 0.0
+		if rs.isReplayPaused {
+			isReplayPaused = 1.0
+		}
+		ch <- gauge(dWalReplayPaused, isReplayPaused)
+		host, port, err := rs.primaryHostPort()
+		if err != nil {
+			c.logger.Warning(err)
+		}
+		ch <- gauge(dWalReceiverStatus, float64(rs.walReceiverStatus), host, port)
+		if rs.replayLag.Valid {
+			ch <- gauge(dWalReplayLag, rs.replayLag.Float64)
+		}
+		if rs.walReceiverStatus > 0 {
+			c.walReceiverMetrics(ch, rs.walReceiver)
+		}
+	} else {
+		if rs.currentLsn.Valid {
+			ch <- counter(dWalCurrentLsn, float64(rs.currentLsn.Int64))
 		}
 	}
+	c.standbyMetrics(ch, rs.standbys)
+	c.replicationSlotMetrics(ch, rs.slots)
+	c.archiverMetrics(ch, rs.archiver)
+	c.logicalReplicationMetrics(ch, rs.subscriptions, c.publications)
 }
 
 func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
@@ -384,8 +823,21 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- dScrapeError
 	ch <- dInfo
 	ch <- dConnections
+	ch <- dConnectionsMaxXactAge
+	ch <- dConnectionsMaxStateDuration
+	ch <- dTransactionDuration
+	ch <- dConnectionsLimit
+	ch <- dConnectionsUtilization
+	ch <- dDbConnectionsLimit
+	ch <- dDbConnectionsUtilization
+	ch <- dRoleConnectionsLimit
+	ch <- dRoleConnectionsUtilization
+	ch <- dConnectionsByClient
 	ch <- dLatency
 	ch <- dLockAwaitingQueries
+	ch <- dLockBlockingChainDepth
+	ch <- dLockBlockedSessions
+	ch <- dLockRootBlockerStateDuration
 	ch <- dSettings
 	ch <- dTopQueryCalls
 	ch <- dTopQueryTime
@@ -396,6 +848,102 @@ func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- dWalCurrentLsn
 	ch <- dWalReceiveLsn
 	ch <- dWalReplyLsn
+	ch <- dWalReplayLag
+	ch <- dWalReceiverInfo
+	ch <- dWalReceiverTimeline
+	ch <- dWalReceiverLastMsgSendTime
+	ch <- dWalReceiverLastMsgReceiptTime
+	ch <- dWalReceiverLatestEndLsn
+	ch <- dWalReceiverLatestEndTime
+	ch <- dReplicationStandbyStatus
+	ch <- dReplicationStandbyLagBytes
+	ch <- dReplicationStandbyLagSeconds
+	ch <- dReplicationSlotActive
+	ch <- dReplicationSlotRetainedWal
+	ch <- dReplicationSlotConfirmedFlushLag
+	ch <- dReplicationSlotWalStatus
+	ch <- dReplicationSlotSafeWalSize
+	ch <- dArchiverArchived
+	ch <- dArchiverFailed
+	ch <- dArchiverLastArchivedTime
+	ch <- dArchiverLastFailedTime
+	ch <- dArchiverLastArchivedLsn
+	ch <- dArchiverLastFailedLsn
+	ch <- dArchiverReadyFiles
+	ch <- dArchiverLag
+	ch <- dSubscriptionWorkers
+	ch <- dSubscriptionReceivedLsn
+	ch <- dSubscriptionLatestEndLsn
+	ch <- dSubscriptionApplyLag
+	ch <- dSubscriptionApplyErrors
+	ch <- dSubscriptionSyncErrors
+	ch <- dPublicationTables
+	ch <- dDbTransactions
+	ch <- dDbBlocksRead
+	ch <- dDbBlocksHit
+	ch <- dDbTuples
+	ch <- dDbTempFiles
+	ch <- dDbTempBytes
+	ch <- dDbDeadlocks
+	ch <- dDbConflicts
+	ch <- dDbChecksumFailures
+	ch <- dDbSessionTime
+	ch <- dDbSessions
+	ch <- dDbSessionsTerminated
+	ch <- dTableScans
+	ch <- dTableRowsRead
+	ch <- dTableRowsModified
+	ch <- dTableRows
+	ch <- dTableLastVacuum
+	ch <- dTableLastAnalyze
+	ch <- dTableVacuums
+	ch <- dTableAnalyzes
+	ch <- dTableBlocksRead
+	ch <- dTableBlocksHit
+	ch <- dIndexScans
+	ch <- dIndexRowsRead
+	ch <- dIndexRowsFetched
+	ch <- dIndexSize
+	ch <- dIndexUnused
+	ch <- dIndexInvalid
+	ch <- dIndexRedundant
+	ch <- dTableBloatBytes
+	ch <- dTableBloatRatio
+	ch <- dIndexBloatBytes
+	ch <- dIndexBloatRatio
+	ch <- dDbSize
+	ch <- dTablespaceSize
+	ch <- dTableSize
+	ch <- dCheckpoints
+	ch <- dCheckpointTime
+	ch <- dBuffersWritten
+	ch <- dBgwriterMaxwrittenClean
+	ch <- dBuffersBackendFsync
+	ch <- dBuffersAllocated
+	ch <- dWalRecords
+	ch <- dWalFpi
+	ch <- dWalBytes
+	ch <- dWalBuffersFull
+	ch <- dIOOperations
+	ch <- dProgressBlocksTotal
+	ch <- dProgressBlocksDone
+	ch <- dProgressBytesTotal
+	ch <- dProgressBytesDone
+	ch <- dProgressDuration
+	ch <- dDbXidAge
+	ch <- dDbMxidAge
+	ch <- dDbXidFreezeRemaining
+	ch <- dDbMxidFreezeRemaining
+	ch <- dDbXidWraparoundRemaining
+	ch <- dDbOldestRelationXidAge
+	ch <- dDbOldestRelationMxidAge
+	ch <- dXminHorizonAge
+	ch <- dPreparedXacts
+	ch <- dPreparedXactsMaxAge
+	ch <- dPreparedXactsMaxXidAge
+	ch <- dLocks
+	ch <- dLocksWaiting
+	ch <- dLocksMaxWait
 }
 
 func desc(name, help string, labels ...string) *prometheus.Desc {
@@ -409,3 +957,15 @@ func gauge(desc *prometheus.Desc, value float64, labels ...string) prometheus.Me
 func counter(desc *prometheus.Desc, value float64, labels ...string) prometheus.Metric {
 	return prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, labels...)
 }
+
+func gaugeIfValid(ch chan<- prometheus.Metric, desc *prometheus.Desc, value sql.NullInt64, labels ...string) {
+	if value.Valid {
+		ch <- gauge(desc, float64(value.Int64), labels...)
+	}
+}
+
+func counterIfValid(ch chan<- prometheus.Metric, desc *prometheus.Desc, value sql.NullInt64, labels ...string) {
+	if value.Valid {
+		ch <- counter(desc, float64(value.Int64), labels...)
+	}
+}
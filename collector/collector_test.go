@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectFunc turns a metrics function of the collector into an unchecked prometheus.Collector,
+// so that its output can be checked with testutil.CollectAndCompare.
+type collectFunc func(ch chan<- prometheus.Metric)
+
+func (f collectFunc) Describe(chan<- *prometheus.Desc) {}
+
+func (f collectFunc) Collect(ch chan<- prometheus.Metric) {
+	f(ch)
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: true}
+}
+
+func nullInt64(n int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: n, Valid: true}
+}
+
+func nullFloat64(f float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: f, Valid: true}
+}
@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var dbnameRe = regexp.MustCompile(`dbname\s*=\s*('(?:[^'\\]|\\.)*'|\S*)`)
+
+// dsnWithDatabase returns a copy of the DSN pointing to another database.
+// Both URL and key=value formats are supported.
+func dsnWithDatabase(dsn, db string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			// don't log url.Parse errors since they might contain security sensitive data
+			return "", fmt.Errorf("failed to parse dsn")
+		}
+		u.Path = "/" + db
+		u.RawPath = ""
+		return u.String(), nil
+	}
+	value := "dbname='" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(db) + "'"
+	if dbnameRe.MatchString(dsn) {
+		return dbnameRe.ReplaceAllLiteralString(dsn, value), nil
+	}
+	return strings.TrimSpace(dsn + " " + value), nil
+}
+
+func (c *Collector) getDatabases(ctx context.Context) ([]string, error) {
+	rows, err := c.db.QueryContext(ctx, `SELECT datname FROM pg_database WHERE NOT datistemplate AND datallowconn`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []string
+	for rows.Next() {
+		var name sql.NullString
+		if err := rows.Scan(&name); err != nil {
+			c.logger.Warning("failed to scan pg_database row:", err)
+			continue
+		}
+		if name.String != "" {
+			res = append(res, name.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	c.dbsLock.Lock()
+	defer c.dbsLock.Unlock()
+	existing := map[string]bool{}
+	for _, name := range res {
+		existing[name] = true
+	}
+	for name, db := range c.dbs {
+		if !existing[name] {
+			_ = db.Close()
+			delete(c.dbs, name)
+		}
+	}
+	return res, nil
+}
+
+// database returns a connection pool to the given database, opening it on first use.
+func (c *Collector) database(name string) (*sql.DB, error) {
+	c.dbsLock.Lock()
+	defer c.dbsLock.Unlock()
+	if db := c.dbs[name]; db != nil {
+		return db, nil
+	}
+	dsn, err := dsnWithDatabase(c.dsn, name)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// a second connection lets periodic expensive queries run without delaying the regular snapshots
+	db.SetMaxOpenConns(2)
+	db.SetMaxIdleConns(1)
+	c.dbs[name] = db
+	return db, nil
+}
+
+// forEachDatabase calls f for every database which allows connections.
+// Per-database errors are logged and don't stop the iteration, the first one is returned.
+func (c *Collector) forEachDatabase(ctx context.Context, f func(name string, db *sql.DB) error) error {
+	names, err := c.getDatabases(ctx)
+	if err != nil {
+		c.logger.Warning(err)
+		return err
+	}
+	var firstErr error
+	for _, name := range names {
+		db, err := c.database(name)
+		if err == nil {
+			err = f(name, db)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+			c.logger.Warning(err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return firstErr
+}
+
+func (c *Collector) closeDatabases() {
+	c.dbsLock.Lock()
+	defer c.dbsLock.Unlock()
+	for name, db := range c.dbs {
+		_ = db.Close()
+		delete(c.dbs, name)
+	}
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"github.com/coroot/coroot-pg-agent/obfuscate"
+)
+
+// LockNode is a session in the blocking tree: its children are the sessions waiting for it.
+// A session waiting for several others is attached only under the first one found walking the tree breadth-first,
+// BlockedBy lists all of them.
+type LockNode struct {
+	Pid             int          `json:"pid"`
+	DB              string       `json:"db"`
+	User            string       `json:"user"`
+	ApplicationName string       `json:"application_name"`
+	Query           string       `json:"query"`
+	State           string       `json:"state"`
+	WaitEventType   string       `json:"wait_event_type"`
+	WaitEvent       string       `json:"wait_event"`
+	XactAgeSeconds  float64      `json:"xact_age_seconds"`
+	StateAgeSeconds float64      `json:"state_age_seconds"`
+	AwaitedLock     *AwaitedLock `json:"awaited_lock,omitempty"`
+	BlockedBy       []int        `json:"blocked_by,omitempty"`
+	Children        []*LockNode  `json:"children,omitempty"`
+}
+
+// LockTree returns the blocking trees built from the latest snapshot of pg_stat_activity, one per root blocker.
+// Every session appears in the result only once, so the size of the result is bounded by the number of sessions
+// even for lock queues, where each waiter is blocked by all the waiters queued before it.
+func (c *Collector) LockTree() []*LockNode {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	res := []*LockNode{}
+	if c.saCurr == nil {
+		return res
+	}
+	graph := newWaitForGraph(c.saCurr.connections)
+	visited := map[int]bool{}
+	for _, root := range graph.roots() {
+		rootNode := c.lockNode(graph, root)
+		visited[root] = true
+		level := []*LockNode{rootNode}
+		for len(level) > 0 {
+			var next []*LockNode
+			for _, n := range level {
+				for _, w := range graph.waiters(n.Pid) {
+					if visited[w] {
+						continue
+					}
+					visited[w] = true
+					child := c.lockNode(graph, w)
+					n.Children = append(n.Children, child)
+					next = append(next, child)
+				}
+			}
+			level = next
+		}
+		res = append(res, rootNode)
+	}
+	return res
+}
+
+func (c *Collector) lockNode(graph *waitForGraph, pid int) *LockNode {
+	n := &LockNode{Pid: pid, BlockedBy: graph.blockedBy[pid]}
+	if conn, ok := c.saCurr.connections[pid]; ok {
+		n.DB = conn.DB.String
+		n.User = conn.User.String
+		n.ApplicationName = conn.ApplicationName.String
+		n.Query = obfuscate.Sql(conn.Query.String)
+		n.State = conn.State.String
+		n.WaitEventType = conn.WaitEventType.String
+		n.WaitEvent = conn.WaitEvent.String
+		if conn.XactStart.Valid {
+			n.XactAgeSeconds = c.saCurr.ts.Sub(conn.XactStart.Time).Seconds()
+		}
+		if conn.StateChange.Valid {
+			n.StateAgeSeconds = c.saCurr.ts.Sub(conn.StateChange.Time).Seconds()
+		}
+	}
+	if l, ok := c.awaitedLocks[pid]; ok {
+		n.AwaitedLock = &l
+	}
+	return n
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockTree(t *testing.T) {
+	ts := time.Now()
+	c := &Collector{}
+	assert.Equal(t, []*LockNode{}, c.LockTree())
+
+	c.saCurr = &saSnapshot{
+		ts: ts,
+		connections: map[int]Connection{
+			1: {
+				DB:          sql.NullString{String: "db", Valid: true},
+				User:        sql.NullString{String: "app", Valid: true},
+				Query:       sql.NullString{String: "UPDATE users SET name = 'foo' WHERE id = 1", Valid: true},
+				State:       sql.NullString{String: "idle in transaction", Valid: true},
+				XactStart:   sql.NullTime{Time: ts.Add(-time.Minute), Valid: true},
+				StateChange: sql.NullTime{Time: ts.Add(-30 * time.Second), Valid: true},
+			},
+			2: {BlockingPids: []int64{1}},
+			3: {BlockingPids: []int64{2}},
+		},
+	}
+	c.awaitedLocks = map[int]AwaitedLock{
+		2: {Locktype: "transactionid", Mode: "ShareLock"},
+	}
+	assert.Equal(t,
+		[]*LockNode{
+			{
+				Pid:             1,
+				DB:              "db",
+				User:            "app",
+				Query:           "update users set name = ? where id = ?",
+				State:           "idle in transaction",
+				XactAgeSeconds:  60,
+				StateAgeSeconds: 30,
+				Children: []*LockNode{
+					{
+						Pid:         2,
+						AwaitedLock: &AwaitedLock{Locktype: "transactionid", Mode: "ShareLock"},
+						BlockedBy:   []int{1},
+						Children:    []*LockNode{{Pid: 3, BlockedBy: []int{2}}},
+					},
+				},
+			},
+		},
+		c.LockTree(),
+	)
+}
+
+func TestLockTreeQueue(t *testing.T) {
+	// sessions queued for a lock on the same row are blocked by the holder and all the waiters ahead of them
+	const waiters = 100
+	connections := map[int]Connection{1: {}}
+	for pid := 2; pid <= waiters+1; pid++ {
+		var blockingPids []int64
+		for b := 1; b < pid; b++ {
+			blockingPids = append(blockingPids, int64(b))
+		}
+		connections[pid] = Connection{BlockingPids: blockingPids}
+	}
+	c := &Collector{saCurr: &saSnapshot{ts: time.Now(), connections: connections}}
+
+	tree := c.LockTree()
+	require.Len(t, tree, 1)
+	root := tree[0]
+	assert.Equal(t, 1, root.Pid)
+	require.Len(t, root.Children, waiters)
+	for i, n := range root.Children {
+		assert.Equal(t, i+2, n.Pid)
+		assert.Len(t, n.BlockedBy, i+1)
+		assert.Empty(t, n.Children)
+	}
+}
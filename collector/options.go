@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	collectorSettings         = "settings"
+	collectorReplication      = "replication"
+	collectorQueries          = "queries"
+	collectorStatDatabase     = "stat_database"
+	collectorTables           = "tables"
+	collectorIndexes          = "indexes"
+	collectorBloat            = "bloat"
+	collectorBgwriter         = "bgwriter"
+	collectorWal              = "wal"
+	collectorStatIO           = "stat_io"
+	collectorProgress         = "progress"
+	collectorWraparound       = "wraparound"
+	collectorXminHorizon      = "xmin_horizon"
+	collectorLocks            = "locks"
+	collectorSizes            = "sizes"
+	collectorPreparedXacts    = "prepared_xacts"
+	collectorConnectionLimits = "connection_limits"
+	collectorClients          = "clients"
+
+	// collected along with the replication status
+	collectorReplicationSlots   = "replication_slots"
+	collectorArchiver           = "archiver"
+	collectorLogicalReplication = "logical_replication"
+)
+
+var defaultCollectorOptions = map[string]CollectorOptions{
+	collectorSettings:         {},
+	collectorReplication:      {},
+	collectorQueries:          {TopN: topQueriesN},
+	collectorStatDatabase:     {},
+	collectorTables:           {TopN: topTablesN},
+	collectorIndexes:          {TopN: topIndexesN},
+	collectorBloat:            {TopN: topBloatN, Interval: 5 * time.Minute},
+	collectorBgwriter:         {},
+	collectorWal:              {},
+	collectorStatIO:           {},
+	collectorProgress:         {},
+	collectorWraparound:       {},
+	collectorXminHorizon:      {},
+	collectorLocks:            {TopN: topLockRelationsN},
+	collectorSizes:            {TopN: topRelationSizesN, Interval: 5 * time.Minute, Timeout: time.Minute},
+	collectorPreparedXacts:    {},
+	collectorConnectionLimits: {},
+	collectorClients:          {Enabled: boolPtr(false)},
+
+	collectorReplicationSlots:   {},
+	collectorArchiver:           {},
+	collectorLogicalReplication: {},
+}
+
+// supportedOptions lists the options taken into account by each collector besides `enabled`
+var supportedOptions = map[string][]string{
+	collectorQueries: {"top_n"},
+	collectorTables:  {"top_n"},
+	collectorIndexes: {"top_n"},
+	collectorBloat:   {"top_n", "interval", "timeout", "exact"},
+	collectorLocks:   {"top_n"},
+	collectorSizes:   {"top_n", "interval", "timeout"},
+	collectorClients: {"networks", "hostname_regex"},
+}
+
+type Options struct {
+	ScrapeInterval time.Duration
+	CollectTimeout time.Duration
+	Collectors     map[string]CollectorOptions
+}
+
+func (o Options) Validate() error {
+	for name, co := range o.Collectors {
+		if _, ok := defaultCollectorOptions[name]; !ok {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+		for _, option := range co.set() {
+			if !contains(supportedOptions[name], option) {
+				return fmt.Errorf("collector %s: unsupported option %s", name, option)
+			}
+		}
+		if co.TopN < 0 {
+			return fmt.Errorf("collector %s: top_n must not be negative", name)
+		}
+		if co.Interval < 0 {
+			return fmt.Errorf("collector %s: interval must not be negative", name)
+		}
+		if co.Timeout < 0 {
+			return fmt.Errorf("collector %s: timeout must not be negative", name)
+		}
+		if _, err := newClientAggregator(co); err != nil {
+			return fmt.Errorf("collector %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (o Options) collector(name string) CollectorOptions {
+	return defaultCollectorOptions[name].Merge(o.Collectors[name])
+}
+
+type CollectorOptions struct {
+	Enabled  *bool         `yaml:"enabled"`
+	TopN     int           `yaml:"top_n"`
+	Interval time.Duration `yaml:"interval"`
+	Timeout  time.Duration `yaml:"timeout"`
+	Exact    *bool         `yaml:"exact"`
+
+	Networks      []string `yaml:"networks"`
+	HostnameRegex string   `yaml:"hostname_regex"`
+}
+
+// Merge returns a copy of the options with the fields explicitly set in other overridden.
+func (o CollectorOptions) Merge(other CollectorOptions) CollectorOptions {
+	if other.Enabled != nil {
+		o.Enabled = other.Enabled
+	}
+	if other.TopN != 0 {
+		o.TopN = other.TopN
+	}
+	if other.Interval != 0 {
+		o.Interval = other.Interval
+	}
+	if other.Timeout != 0 {
+		o.Timeout = other.Timeout
+	}
+	if other.Exact != nil {
+		o.Exact = other.Exact
+	}
+	if other.Networks != nil {
+		o.Networks = other.Networks
+	}
+	if other.HostnameRegex != "" {
+		o.HostnameRegex = other.HostnameRegex
+	}
+	return o
+}
+
+// set returns the names of the options explicitly set besides `enabled`.
+func (o CollectorOptions) set() []string {
+	var res []string
+	if o.TopN != 0 {
+		res = append(res, "top_n")
+	}
+	if o.Interval != 0 {
+		res = append(res, "interval")
+	}
+	if o.Timeout != 0 {
+		res = append(res, "timeout")
+	}
+	if o.Exact != nil {
+		res = append(res, "exact")
+	}
+	if o.Networks != nil {
+		res = append(res, "networks")
+	}
+	if o.HostnameRegex != "" {
+		res = append(res, "hostname_regex")
+	}
+	return res
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (o CollectorOptions) enabled() bool {
+	return o.Enabled == nil || *o.Enabled
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+// timeout limits a periodic collector run, by default it may take the whole interval.
+func (o CollectorOptions) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return o.Interval
+}
@@ -0,0 +1,215 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The estimation queries are based on https://github.com/ioguix/pgsql-bloat-estimation.
+// They rely on the statistics collected by ANALYZE, so the results for never analyzed relations are unreliable.
+
+const tableBloatQuery = `
+SELECT schemaname, tblname, bs*tblpages AS real_size,
+	CASE WHEN tblpages - est_tblpages_ff > 0 THEN (tblpages-est_tblpages_ff)*bs ELSE 0 END AS bloat_size
+FROM (
+	SELECT ceil(reltuples / ((bs-page_hdr)*fillfactor/(tpl_size*100))) + ceil(toasttuples / 4) AS est_tblpages_ff,
+		tblpages, bs, schemaname, tblname, is_na
+	FROM (
+		SELECT (4 + tpl_hdr_size + tpl_data_size + (2*ma)
+				- CASE WHEN tpl_hdr_size%ma = 0 THEN ma ELSE tpl_hdr_size%ma END
+				- CASE WHEN ceil(tpl_data_size)::int%ma = 0 THEN ma ELSE ceil(tpl_data_size)::int%ma END
+			) AS tpl_size,
+			(heappages + toastpages) AS tblpages, reltuples, toasttuples, bs, page_hdr, schemaname, tblname, fillfactor, is_na
+		FROM (
+			SELECT ns.nspname AS schemaname, tbl.relname AS tblname, tbl.reltuples,
+				tbl.relpages AS heappages, coalesce(toast.relpages, 0) AS toastpages,
+				coalesce(toast.reltuples, 0) AS toasttuples,
+				coalesce(substring(array_to_string(tbl.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 100) AS fillfactor,
+				current_setting('block_size')::numeric AS bs,
+				CASE WHEN version()~'mingw32' OR version()~'64-bit|x86_64|ppc64|ia64|amd64' THEN 8 ELSE 4 END AS ma,
+				24 AS page_hdr,
+				23 + CASE WHEN max(coalesce(s.null_frac,0)) > 0 THEN (7 + count(s.attname)) / 8 ELSE 0::int END
+					+ CASE WHEN bool_or(att.attname = 'oid' AND att.attnum < 0) THEN 4 ELSE 0 END AS tpl_hdr_size,
+				sum((1-coalesce(s.null_frac, 0)) * coalesce(s.avg_width, 0)) AS tpl_data_size,
+				bool_or(att.atttypid = 'pg_catalog.name'::regtype)
+					OR sum(CASE WHEN att.attnum > 0 THEN 1 ELSE 0 END) <> count(s.attname) AS is_na
+			FROM pg_attribute AS att
+				JOIN pg_class AS tbl ON att.attrelid = tbl.oid
+				JOIN pg_namespace AS ns ON ns.oid = tbl.relnamespace
+				LEFT JOIN pg_stats AS s ON s.schemaname = ns.nspname AND s.tablename = tbl.relname AND s.inherited = false AND s.attname = att.attname
+				LEFT JOIN pg_class AS toast ON tbl.reltoastrelid = toast.oid
+			WHERE NOT att.attisdropped AND tbl.relkind IN ('r','m') AND tbl.relpages > 0 AND tbl.reltuples >= 0
+				AND ns.nspname NOT IN ('pg_catalog', 'information_schema') AND ns.nspname !~ '^pg_toast'
+			GROUP BY 1,2,3,4,5,6,7,8,9,10
+		) AS s
+	) AS s2
+) AS s3
+WHERE NOT is_na`
+
+const indexBloatQuery = `
+SELECT nspname, tblname, idxname, bs*relpages AS real_size,
+	CASE WHEN relpages > est_pages_ff THEN bs*(relpages-est_pages_ff) ELSE 0 END AS bloat_size
+FROM (
+	SELECT coalesce(1 + ceil(reltuples/floor((bs-pageopqdata-pagehdr)*fillfactor/(100*(4+nulldatahdrwidth)::float))), 0) AS est_pages_ff,
+		bs, nspname, tblname, idxname, relpages, is_na
+	FROM (
+		SELECT maxalign, bs, nspname, tblname, idxname, reltuples, relpages, fillfactor,
+			(index_tuple_hdr_bm + maxalign
+				- CASE WHEN index_tuple_hdr_bm%maxalign = 0 THEN maxalign ELSE index_tuple_hdr_bm%maxalign END
+				+ nulldatawidth + maxalign
+				- CASE WHEN nulldatawidth = 0 THEN 0 WHEN nulldatawidth::integer%maxalign = 0 THEN maxalign ELSE nulldatawidth::integer%maxalign END
+			)::numeric AS nulldatahdrwidth,
+			pagehdr, pageopqdata, is_na
+		FROM (
+			SELECT n.nspname, i.tblname, i.idxname, i.reltuples, i.relpages, i.idxoid, i.fillfactor,
+				current_setting('block_size')::numeric AS bs,
+				CASE WHEN version() ~ 'mingw32' OR version() ~ '64-bit|x86_64|ppc64|ia64|amd64' THEN 8 ELSE 4 END AS maxalign,
+				24 AS pagehdr,
+				16 AS pageopqdata,
+				CASE WHEN max(coalesce(s.null_frac,0)) = 0 THEN 8 ELSE 8 + ((32 + 8 - 1) / 8) END AS index_tuple_hdr_bm,
+				sum((1-coalesce(s.null_frac, 0)) * coalesce(s.avg_width, 1024)) AS nulldatawidth,
+				max(CASE WHEN i.atttypid = 'pg_catalog.name'::regtype THEN 1 ELSE 0 END) > 0 AS is_na
+			FROM (
+				SELECT ct.relname AS tblname, ct.relnamespace, ic.idxname, ic.reltuples, ic.relpages, ic.idxoid, ic.fillfactor,
+					coalesce(a1.attname, a2.attname) AS attname,
+					coalesce(a1.atttypid, a2.atttypid) AS atttypid,
+					CASE WHEN a1.attnum IS NULL THEN ic.idxname ELSE ct.relname END AS attrelname
+				FROM (
+					SELECT idxname, reltuples, relpages, tbloid, idxoid, fillfactor, indkey,
+						generate_series(1, indnatts) AS attpos
+					FROM (
+						SELECT ci.relname AS idxname, ci.reltuples, ci.relpages, i.indrelid AS tbloid, i.indexrelid AS idxoid,
+							coalesce(substring(array_to_string(ci.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 90) AS fillfactor,
+							i.indnatts,
+							string_to_array(textin(int2vectorout(i.indkey)), ' ')::int[] AS indkey
+						FROM pg_index i
+						JOIN pg_class ci ON ci.oid = i.indexrelid
+						WHERE ci.relam = (SELECT oid FROM pg_am WHERE amname = 'btree') AND ci.relpages > 0 AND ci.reltuples >= 0
+					) AS idx_data
+				) AS ic
+				JOIN pg_class ct ON ct.oid = ic.tbloid
+				LEFT JOIN pg_attribute a1 ON ic.indkey[ic.attpos] <> 0 AND a1.attrelid = ic.tbloid AND a1.attnum = ic.indkey[ic.attpos]
+				LEFT JOIN pg_attribute a2 ON ic.indkey[ic.attpos] = 0 AND a2.attrelid = ic.idxoid AND a2.attnum = ic.attpos
+			) i
+			JOIN pg_namespace n ON n.oid = i.relnamespace
+			JOIN pg_stats s ON s.schemaname = n.nspname AND s.tablename = i.attrelname AND s.attname = i.attname
+			WHERE n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname !~ '^pg_toast'
+			GROUP BY 1,2,3,4,5,6,7,8,9,10,11
+		) AS rows_data_stats
+	) AS rows_hdr_pdg_stats
+) AS relation_stats
+WHERE NOT is_na`
+
+// exactTableBloatQuery uses pgstattuple_approx, which skips the pages marked all-visible in the visibility map,
+// but still scans the rest of the table, so it's limited to the largest tables.
+const exactTableBloatQuery = `
+SELECT n.nspname, c.relname, s.table_len, s.approx_free_space + s.dead_tuple_len
+FROM (
+	SELECT c.oid, c.relname, c.relnamespace FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r','m') AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname !~ '^pg_toast'
+	ORDER BY pg_table_size(c.oid) DESC LIMIT $1
+) c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+CROSS JOIN LATERAL pgstattuple_approx(c.oid) s`
+
+type bloatStat struct {
+	db     string
+	schema sql.NullString
+	table  sql.NullString
+	index  sql.NullString
+	size   sql.NullFloat64
+	bloat  sql.NullFloat64
+}
+
+func (c *Collector) getBloat(ctx context.Context, topN int, exact bool) ([]bloatStat, error) {
+	var res []bloatStat
+	err := c.forEachDatabase(ctx, func(name string, db *sql.DB) error {
+		hasPgstattuple := false
+		if exact {
+			if err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pgstattuple')`).Scan(&hasPgstattuple); err != nil {
+				return err
+			}
+		}
+		var tables []bloatStat
+		var err error
+		if hasPgstattuple {
+			tables, err = c.queryBloat(ctx, db, name, false, exactTableBloatQuery, topN)
+		} else {
+			tables, err = c.queryBloat(ctx, db, name, false, tableBloatQuery)
+		}
+		if err != nil {
+			return err
+		}
+		indexes, err := c.queryBloat(ctx, db, name, true, indexBloatQuery)
+		if err != nil {
+			return err
+		}
+		res = append(res, tables...)
+		res = append(res, indexes...)
+		return nil
+	})
+	return topBloat(res, topN), err
+}
+
+func (c *Collector) queryBloat(ctx context.Context, db *sql.DB, name string, indexes bool, query string, args ...interface{}) ([]bloatStat, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []bloatStat
+	for rows.Next() {
+		s := bloatStat{db: name}
+		if indexes {
+			err = rows.Scan(&s.schema, &s.table, &s.index, &s.size, &s.bloat)
+		} else {
+			err = rows.Scan(&s.schema, &s.table, &s.size, &s.bloat)
+		}
+		if err != nil {
+			c.logger.Warning("failed to scan bloat row:", err)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func topBloat(all []bloatStat, n int) []bloatStat {
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].size.Float64 > all[j].size.Float64
+	})
+	if n > len(all) {
+		n = len(all)
+	}
+	return all[:n]
+}
+
+func (c *Collector) updateBloat() {
+	o := c.options.collector(collectorBloat)
+	ctx, cancelFunc := context.WithTimeout(c.ctx, o.timeout())
+	defer cancelFunc()
+	bloat, err := c.getBloat(ctx, o.TopN, o.Exact != nil && *o.Exact)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.bloat = bloat
+	c.periodicErrors[collectorBloat] = err
+}
+
+func (c *Collector) bloatMetrics(ch chan<- prometheus.Metric) {
+	for _, s := range c.bloat {
+		if !s.size.Valid || !s.bloat.Valid || s.size.Float64 <= 0 {
+			continue
+		}
+		ratio := s.bloat.Float64 / s.size.Float64
+		if s.index.Valid {
+			ch <- gauge(dIndexBloatBytes, s.bloat.Float64, s.db, s.schema.String, s.table.String, s.index.String)
+			ch <- gauge(dIndexBloatRatio, ratio, s.db, s.schema.String, s.table.String, s.index.String)
+		} else {
+			ch <- gauge(dTableBloatBytes, s.bloat.Float64, s.db, s.schema.String, s.table.String)
+			ch <- gauge(dTableBloatRatio, ratio, s.db, s.schema.String, s.table.String)
+		}
+	}
+}
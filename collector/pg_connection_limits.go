@@ -0,0 +1,93 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type connectionLimits struct {
+	available int64
+	databases map[string]int64
+	roles     map[string]int64
+}
+
+// getConnectionLimits returns the number of connections available to non-superusers
+// and the explicitly set per-database and per-role limits, -1 (no limit) is omitted.
+func (c *Collector) getConnectionLimits(ctx context.Context, version semver.Version) (*connectionLimits, error) {
+	res := &connectionLimits{databases: map[string]int64{}, roles: map[string]int64{}}
+
+	// the reserved slots are available only to superusers (and pg_use_reserved_connections members in 16+);
+	// the settings are read here rather than taken from the `settings` collector, which may be disabled
+	query := `SELECT current_setting('max_connections')::int - current_setting('superuser_reserved_connections')::int`
+	// `reserved_connections` has been introduced in 16
+	if semver.MustParseRange(">=16.0.0")(version) {
+		query += ` - current_setting('reserved_connections')::int`
+	}
+	if err := c.db.QueryRowContext(ctx, query).Scan(&res.available); err != nil {
+		return nil, err
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT 'db', datname, datconnlimit FROM pg_database WHERE datconnlimit >= 0 AND datallowconn AND NOT datistemplate
+		UNION ALL
+		SELECT 'role', rolname, rolconnlimit FROM pg_roles WHERE rolconnlimit >= 0 AND rolcanlogin`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			kind, name sql.NullString
+			limit      int64
+		)
+		if err := rows.Scan(&kind, &name, &limit); err != nil {
+			c.logger.Warning("failed to scan connection limit row:", err)
+			continue
+		}
+		switch kind.String {
+		case "db":
+			res.databases[name.String] = limit
+		case "role":
+			res.roles[name.String] = limit
+		}
+	}
+	return res, rows.Err()
+}
+
+func (c *Collector) connectionLimitMetrics(ch chan<- prometheus.Metric) {
+	if c.saCurr == nil || c.connectionLimits == nil {
+		return
+	}
+	var total float64
+	byDB := map[string]float64{}
+	byRole := map[string]float64{}
+	for _, conn := range c.saCurr.connections {
+		if !conn.IsClientBackend() {
+			continue
+		}
+		total++
+		byDB[conn.DB.String]++
+		byRole[conn.User.String]++
+	}
+
+	ch <- gauge(dConnectionsLimit, float64(c.connectionLimits.available))
+	if c.connectionLimits.available > 0 {
+		ch <- gauge(dConnectionsUtilization, total/float64(c.connectionLimits.available))
+	}
+	for db, limit := range c.connectionLimits.databases {
+		ch <- gauge(dDbConnectionsLimit, float64(limit), db)
+		if limit > 0 {
+			ch <- gauge(dDbConnectionsUtilization, byDB[db]/float64(limit), db)
+		}
+	}
+	for role, limit := range c.connectionLimits.roles {
+		ch <- gauge(dRoleConnectionsLimit, float64(limit), role)
+		if limit > 0 {
+			ch <- gauge(dRoleConnectionsUtilization, byRole[role]/float64(limit), role)
+		}
+	}
+}
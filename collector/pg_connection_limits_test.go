@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionLimitMetrics(t *testing.T) {
+	c := &Collector{
+		saCurr: &saSnapshot{connections: map[int]Connection{
+			1: {DB: nullString("db"), User: nullString("app"), BackendType: nullString("client backend")},
+			2: {DB: nullString("db"), User: nullString("app"), BackendType: nullString("client backend")},
+			3: {DB: nullString("other"), User: nullString("admin"), BackendType: nullString("client backend")},
+			4: {BackendType: nullString("autovacuum launcher")},
+		}},
+		connectionLimits: &connectionLimits{
+			available: 97,
+			databases: map[string]int64{"db": 10, "locked": 0},
+			roles:     map[string]int64{"app": 4},
+		},
+	}
+
+	assert.NoError(t, testutil.CollectAndCompare(collectFunc(c.connectionLimitMetrics), strings.NewReader(`
+# HELP pg_connections_limit Number of connections available to non-superusers: max_connections minus the reserved ones
+# TYPE pg_connections_limit gauge
+pg_connections_limit 97
+# HELP pg_connections_utilization Ratio of client connections to the number of connections available to non-superusers
+# TYPE pg_connections_utilization gauge
+pg_connections_utilization 0.030927835051546393
+# HELP pg_db_connections_limit Maximum number of connections to the database (datconnlimit)
+# TYPE pg_db_connections_limit gauge
+pg_db_connections_limit{db="db"} 10
+pg_db_connections_limit{db="locked"} 0
+# HELP pg_db_connections_utilization Ratio of client connections to the database to its connection limit
+# TYPE pg_db_connections_utilization gauge
+pg_db_connections_utilization{db="db"} 0.2
+# HELP pg_role_connections_limit Maximum number of connections of the role (rolconnlimit)
+# TYPE pg_role_connections_limit gauge
+pg_role_connections_limit{user="app"} 4
+# HELP pg_role_connections_utilization Ratio of client connections of the role to its connection limit
+# TYPE pg_role_connections_utilization gauge
+pg_role_connections_utilization{user="app"} 0.5
+`)))
+}
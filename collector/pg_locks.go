@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type lockStat struct {
+	db       string
+	relation sql.NullString
+	locktype sql.NullString
+	mode     sql.NullString
+	count    int64
+	waiting  int64
+	maxWait  sql.NullFloat64
+}
+
+// AwaitedLock is a lock a session is waiting for to be granted
+type AwaitedLock struct {
+	Locktype string `json:"locktype"`
+	Mode     string `json:"mode"`
+	Relation string `json:"relation,omitempty"`
+}
+
+type lockRelation struct {
+	db       string
+	relation string
+}
+
+func (c *Collector) getLocks(ctx context.Context, version semver.Version, topN int) ([]lockStat, map[int]AwaitedLock, error) {
+	var maxWait string
+	switch {
+	case semver.MustParseRange(">=9.4.0 <14.0.0")(version):
+		maxWait = "null::float"
+	// `pg_locks.waitstart` has been introduced in 14
+	case semver.MustParseRange(">=14.0.0")(version):
+		maxWait = "extract(epoch from now() - min(l.waitstart))"
+	default:
+		return nil, nil, fmt.Errorf("postgres version %s is not supported", version)
+	}
+	// relation OIDs can be resolved to names only within the same database
+	query := `
+		SELECT coalesce(l.relation::regclass::text, ''), l.locktype, l.mode, count(1), count(1) FILTER (WHERE NOT l.granted), ` + maxWait + `
+		FROM pg_locks l
+		WHERE %s
+		GROUP BY 1, 2, 3`
+	awaitedQuery := `
+		SELECT l.pid, l.locktype, l.mode, coalesce(l.relation::regclass::text, '')
+		FROM pg_locks l
+		WHERE NOT l.granted AND (%s)`
+
+	var res []lockStat
+	awaited := map[int]AwaitedLock{}
+	collect := func(db *sql.DB, dbName string, filter string) error {
+		locks, err := c.queryLocks(ctx, db, dbName, fmt.Sprintf(query, filter))
+		res = append(res, locks...)
+		if err != nil {
+			return err
+		}
+		return c.queryAwaitedLocks(ctx, db, fmt.Sprintf(awaitedQuery, filter), awaited)
+	}
+	err := c.forEachDatabase(ctx, func(name string, db *sql.DB) error {
+		return collect(db, name, "l.database = (SELECT oid FROM pg_database WHERE datname = current_database())")
+	})
+	if err != nil {
+		return topLocks(res, topN), awaited, err
+	}
+	// transaction IDs and locks on shared catalogs don't belong to any database
+	if err = collect(c.db, "", "l.database IS NULL OR l.database = 0"); err != nil { // not logged by forEachDatabase
+		c.logger.Warning(err)
+	}
+	return topLocks(res, topN), awaited, err
+}
+
+func (c *Collector) queryAwaitedLocks(ctx context.Context, db *sql.DB, query string, res map[int]AwaitedLock) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			pid                      int
+			locktype, mode, relation sql.NullString
+		)
+		if err := rows.Scan(&pid, &locktype, &mode, &relation); err != nil {
+			c.logger.Warning("failed to scan pg_locks row:", err)
+			continue
+		}
+		res[pid] = AwaitedLock{Locktype: locktype.String, Mode: mode.String, Relation: relation.String}
+	}
+	return rows.Err()
+}
+
+func (c *Collector) queryLocks(ctx context.Context, db *sql.DB, dbName string, query string) ([]lockStat, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []lockStat
+	for rows.Next() {
+		l := lockStat{db: dbName}
+		if err := rows.Scan(&l.relation, &l.locktype, &l.mode, &l.count, &l.waiting, &l.maxWait); err != nil {
+			c.logger.Warning("failed to scan pg_locks row:", err)
+			continue
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+// topLocks limits the number of relations to the n ones with the most waiting and then held locks.
+// Locks not related to any relation (e.g., transactionid) are always kept.
+func topLocks(all []lockStat, n int) []lockStat {
+	type total struct {
+		count   int64
+		waiting int64
+	}
+	totals := map[lockRelation]*total{}
+	for _, l := range all {
+		if l.relation.String == "" {
+			continue
+		}
+		k := lockRelation{db: l.db, relation: l.relation.String}
+		t := totals[k]
+		if t == nil {
+			t = &total{}
+			totals[k] = t
+		}
+		t.count += l.count
+		t.waiting += l.waiting
+	}
+	if len(totals) <= n {
+		return all
+	}
+	relations := make([]lockRelation, 0, len(totals))
+	for k := range totals {
+		relations = append(relations, k)
+	}
+	sort.Slice(relations, func(i, j int) bool {
+		ti, tj := totals[relations[i]], totals[relations[j]]
+		if ti.waiting != tj.waiting {
+			return ti.waiting > tj.waiting
+		}
+		return ti.count > tj.count
+	})
+	top := map[lockRelation]bool{}
+	for _, k := range relations[:n] {
+		top[k] = true
+	}
+	var res []lockStat
+	for _, l := range all {
+		if l.relation.String == "" || top[lockRelation{db: l.db, relation: l.relation.String}] {
+			res = append(res, l)
+		}
+	}
+	return res
+}
+
+func (c *Collector) lockMetrics(ch chan<- prometheus.Metric) {
+	waiting := map[lockRelation]int64{}
+	maxWait := map[lockRelation]float64{}
+	for _, l := range c.locks {
+		ch <- gauge(dLocks, float64(l.count), l.db, l.relation.String, l.locktype.String, l.mode.String)
+		k := lockRelation{db: l.db, relation: l.relation.String}
+		waiting[k] += l.waiting
+		if l.maxWait.Valid && l.maxWait.Float64 > maxWait[k] {
+			maxWait[k] = l.maxWait.Float64
+		}
+	}
+	for k, w := range waiting {
+		ch <- gauge(dLocksWaiting, float64(w), k.db, k.relation)
+	}
+	for k, w := range maxWait {
+		ch <- gauge(dLocksMaxWait, w, k.db, k.relation)
+	}
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_topLocks(t *testing.T) {
+	lock := func(db, relation, mode string, count, waiting int64) lockStat {
+		return lockStat{
+			db:       db,
+			relation: sql.NullString{String: relation, Valid: true},
+			mode:     sql.NullString{String: mode, Valid: true},
+			count:    count,
+			waiting:  waiting,
+		}
+	}
+	all := []lockStat{
+		lock("", "", "ExclusiveLock", 10, 0),
+		lock("db1", "orders", "AccessShareLock", 5, 0),
+		lock("db1", "orders", "RowExclusiveLock", 5, 0),
+		lock("db1", "users", "AccessShareLock", 20, 0),
+		lock("db2", "orders", "AccessExclusiveLock", 1, 0),
+		lock("db2", "orders", "AccessShareLock", 1, 1),
+	}
+
+	assert.Equal(t, all, topLocks(all, 3))
+
+	assert.Equal(t, []lockStat{all[0], all[3], all[4], all[5]}, topLocks(all, 2))
+
+	assert.Equal(t, []lockStat{all[0], all[4], all[5]}, topLocks(all, 1))
+}
+
+func TestLockMetrics(t *testing.T) {
+	c := &Collector{locks: []lockStat{
+		{db: "db1", relation: nullString("orders"), locktype: nullString("relation"), mode: nullString("AccessShareLock"), count: 5},
+		{db: "db1", relation: nullString("orders"), locktype: nullString("relation"), mode: nullString("AccessExclusiveLock"), count: 2, waiting: 1, maxWait: nullFloat64(12.5)},
+		{db: "db1", relation: nullString("users"), locktype: nullString("relation"), mode: nullString("RowExclusiveLock"), count: 2},
+		// not related to a relation
+		{locktype: nullString("transactionid"), mode: nullString("ExclusiveLock"), count: 3},
+	}}
+
+	// the relations without waiters report 0 waiting locks and no wait time
+	assert.NoError(t, testutil.CollectAndCompare(collectFunc(c.lockMetrics), strings.NewReader(`
+# HELP pg_locks Number of locks held or awaited by lock type, mode and relation
+# TYPE pg_locks gauge
+pg_locks{db="",locktype="transactionid",mode="ExclusiveLock",relation=""} 3
+pg_locks{db="db1",locktype="relation",mode="AccessExclusiveLock",relation="orders"} 2
+pg_locks{db="db1",locktype="relation",mode="AccessShareLock",relation="orders"} 5
+pg_locks{db="db1",locktype="relation",mode="RowExclusiveLock",relation="users"} 2
+# HELP pg_locks_max_wait_seconds The longest time a lock on the relation has been waited for
+# TYPE pg_locks_max_wait_seconds gauge
+pg_locks_max_wait_seconds{db="db1",relation="orders"} 12.5
+# HELP pg_locks_waiting Number of locks not granted yet by relation
+# TYPE pg_locks_waiting gauge
+pg_locks_waiting{db="",relation=""} 0
+pg_locks_waiting{db="db1",relation="orders"} 1
+pg_locks_waiting{db="db1",relation="users"} 0
+`)))
+}
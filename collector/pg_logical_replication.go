@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type subscriptionStatus struct {
+	name             sql.NullString
+	applyWorkers     int64
+	syncWorkers      int64
+	receivedLsn      sql.NullInt64
+	latestEndLsn     sql.NullInt64
+	applyLag         sql.NullFloat64
+	applyErrorsCount sql.NullInt64
+	syncErrorsCount  sql.NullInt64
+}
+
+type publicationStatus struct {
+	db        string
+	name      sql.NullString
+	allTables sql.NullBool
+	tables    sql.NullInt64
+}
+
+func (c *Collector) getSubscriptions(ctx context.Context, version semver.Version) ([]subscriptionStatus, error) {
+	// parallel apply workers (16+) don't receive WAL themselves
+	applyWorker := "relid IS NULL"
+	if semver.MustParseRange(">=16.0.0")(version) {
+		applyWorker = "relid IS NULL AND leader_pid IS NULL"
+	}
+	// `pg_stat_subscription_stats` has been introduced in 15
+	errorCounts := "null::bigint, null::bigint"
+	if semver.MustParseRange(">=15.0.0")(version) {
+		errorCounts = `
+			(SELECT ss.apply_error_count FROM pg_stat_subscription_stats ss WHERE ss.subid = s.subid),
+			(SELECT ss.sync_error_count FROM pg_stat_subscription_stats ss WHERE ss.subid = s.subid)`
+	}
+	// the latest end position is reported back to the publisher on every message including keepalives,
+	// so the time since then doesn't grow while the publisher is idle
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT s.subname,
+			count(s.pid) FILTER (WHERE %[1]s), count(s.pid) FILTER (WHERE s.relid IS NOT NULL),
+			max(s.received_lsn-'0/0') FILTER (WHERE %[1]s), max(s.latest_end_lsn-'0/0') FILTER (WHERE %[1]s),
+			extract(epoch from now() - max(s.latest_end_time) FILTER (WHERE %[1]s)),
+			%[2]s
+		FROM pg_stat_subscription s
+		GROUP BY s.subid, s.subname`, applyWorker, errorCounts))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []subscriptionStatus
+	for rows.Next() {
+		var s subscriptionStatus
+		err := rows.Scan(
+			&s.name, &s.applyWorkers, &s.syncWorkers, &s.receivedLsn, &s.latestEndLsn, &s.applyLag,
+			&s.applyErrorsCount, &s.syncErrorsCount,
+		)
+		if err != nil {
+			c.logger.Warning("failed to scan pg_stat_subscription row:", err)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func (c *Collector) getPublications(ctx context.Context) ([]publicationStatus, error) {
+	var res []publicationStatus
+	err := c.forEachDatabase(ctx, func(name string, db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, `
+			SELECT p.pubname, p.puballtables, count(pt.tablename)
+			FROM pg_publication p LEFT JOIN pg_publication_tables pt ON pt.pubname = p.pubname
+			GROUP BY p.pubname, p.puballtables`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			p := publicationStatus{db: name}
+			if err := rows.Scan(&p.name, &p.allTables, &p.tables); err != nil {
+				c.logger.Warning("failed to scan pg_publication row:", err)
+				continue
+			}
+			res = append(res, p)
+		}
+		return rows.Err()
+	})
+	return res, err
+}
+
+func (c *Collector) logicalReplicationMetrics(ch chan<- prometheus.Metric, subscriptions []subscriptionStatus, publications []publicationStatus) {
+	for _, s := range subscriptions {
+		name := s.name.String
+		ch <- gauge(dSubscriptionWorkers, float64(s.applyWorkers), name, "apply")
+		ch <- gauge(dSubscriptionWorkers, float64(s.syncWorkers), name, "sync")
+		counterIfValid(ch, dSubscriptionReceivedLsn, s.receivedLsn, name)
+		counterIfValid(ch, dSubscriptionLatestEndLsn, s.latestEndLsn, name)
+		if s.applyLag.Valid {
+			ch <- gauge(dSubscriptionApplyLag, s.applyLag.Float64, name)
+		}
+		counterIfValid(ch, dSubscriptionApplyErrors, s.applyErrorsCount, name)
+		counterIfValid(ch, dSubscriptionSyncErrors, s.syncErrorsCount, name)
+	}
+	for _, p := range publications {
+		allTables := "false"
+		if p.allTables.Bool {
+			allTables = "true"
+		}
+		gaugeIfValid(ch, dPublicationTables, p.tables, p.db, p.name.String, allTables)
+	}
+}
@@ -0,0 +1,51 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type preparedXactStat struct {
+	db     sql.NullString
+	owner  sql.NullString
+	count  int64
+	maxAge sql.NullFloat64
+	xidAge sql.NullInt64
+}
+
+// Prepared transactions aren't bound to any backend, so they aren't visible in pg_stat_activity,
+// while holding their locks and xmin until committed or rolled back.
+func (c *Collector) getPreparedXacts(ctx context.Context) ([]preparedXactStat, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT database, owner, count(1), extract(epoch from now() - min(prepared)), max(age(transaction))
+		FROM pg_prepared_xacts
+		GROUP BY database, owner`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []preparedXactStat
+	for rows.Next() {
+		var s preparedXactStat
+		if err := rows.Scan(&s.db, &s.owner, &s.count, &s.maxAge, &s.xidAge); err != nil {
+			c.logger.Warning("failed to scan pg_prepared_xacts row:", err)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func (c *Collector) preparedXactMetrics(ch chan<- prometheus.Metric) {
+	for _, s := range c.preparedXacts {
+		db, owner := s.db.String, s.owner.String
+		ch <- gauge(dPreparedXacts, float64(s.count), db, owner)
+		if s.maxAge.Valid {
+			ch <- gauge(dPreparedXactsMaxAge, s.maxAge.Float64, db, owner)
+		}
+		gaugeIfValid(ch, dPreparedXactsMaxXidAge, s.xidAge, db, owner)
+	}
+}
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreparedXactMetrics(t *testing.T) {
+	c := &Collector{preparedXacts: []preparedXactStat{
+		{db: nullString("db"), owner: nullString("app"), count: 2, maxAge: nullFloat64(3600), xidAge: nullInt64(150000)},
+		// the xid age is absent rather than zero when unknown
+		{db: nullString("db"), owner: nullString("batch"), count: 1, maxAge: nullFloat64(5)},
+	}}
+
+	assert.NoError(t, testutil.CollectAndCompare(collectFunc(c.preparedXactMetrics), strings.NewReader(`
+# HELP pg_prepared_xacts Number of transactions prepared for two-phase commit
+# TYPE pg_prepared_xacts gauge
+pg_prepared_xacts{db="db",owner="app"} 2
+pg_prepared_xacts{db="db",owner="batch"} 1
+# HELP pg_prepared_xacts_max_age_seconds Time elapsed since the oldest transaction was prepared for two-phase commit
+# TYPE pg_prepared_xacts_max_age_seconds gauge
+pg_prepared_xacts_max_age_seconds{db="db",owner="app"} 3600
+pg_prepared_xacts_max_age_seconds{db="db",owner="batch"} 5
+# HELP pg_prepared_xacts_max_xid_age Age of the transaction ID of the oldest prepared transaction
+# TYPE pg_prepared_xacts_max_xid_age gauge
+pg_prepared_xacts_max_xid_age{db="db",owner="app"} 150000
+`)))
+}
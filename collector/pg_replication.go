@@ -7,9 +7,11 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 var (
@@ -35,9 +37,90 @@ type replicationStatus struct {
 	replyLsn   sql.NullInt64
 
 	isReplayPaused bool
+	replayLag      sql.NullFloat64
 
 	walReceiverStatus     int64
+	walReceiver           walReceiver
 	primaryConnectionInfo string
+
+	standbys []standbyStatus
+	slots    []replicationSlot
+	archiver *archiverStatus
+
+	subscriptions []subscriptionStatus
+
+	// errors of the sub-collectors, which don't affect the rest of the status
+	errors []error
+}
+
+type archiverStatus struct {
+	archivedCount    sql.NullInt64
+	lastArchivedWal  sql.NullString
+	lastArchivedTime sql.NullFloat64
+	failedCount      sql.NullInt64
+	lastFailedWal    sql.NullString
+	lastFailedTime   sql.NullFloat64
+	walSegmentSize   sql.NullInt64
+	readyFiles       sql.NullInt64
+	lag              sql.NullFloat64
+}
+
+var walFileNameRe = regexp.MustCompile(`^[0-9A-F]{24}`)
+
+// walFileLsn returns the starting WAL position of the segment file, e.g., 000000010000000A000000FF.
+// History and backup history files are ignored.
+func walFileLsn(name string, segmentSize int64) (int64, bool) {
+	if segmentSize <= 0 || len(name) != 24 || !walFileNameRe.MatchString(name) {
+		return 0, false
+	}
+	logId, err := strconv.ParseInt(name[8:16], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	segId, err := strconv.ParseInt(name[16:24], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return logId<<32 + segId*segmentSize, true
+}
+
+type walReceiver struct {
+	status             sql.NullString
+	receivedTimeline   sql.NullInt64
+	lastMsgSendTime    sql.NullFloat64
+	lastMsgReceiptTime sql.NullFloat64
+	latestEndLsn       sql.NullInt64
+	latestEndTime      sql.NullFloat64
+	slotName           sql.NullString
+}
+
+type replicationSlot struct {
+	name                   sql.NullString
+	slotType               sql.NullString
+	plugin                 sql.NullString
+	database               sql.NullString
+	active                 sql.NullBool
+	retainedWalBytes       sql.NullInt64
+	confirmedFlushLagBytes sql.NullInt64
+	walStatus              sql.NullString
+	safeWalSize            sql.NullInt64
+}
+
+type standbyStatus struct {
+	pid             sql.NullInt64
+	applicationName sql.NullString
+	clientAddr      sql.NullString
+	state           sql.NullString
+	syncState       sql.NullString
+
+	sentLagBytes   sql.NullInt64
+	writeLagBytes  sql.NullInt64
+	flushLagBytes  sql.NullInt64
+	replayLagBytes sql.NullInt64
+
+	writeLag  sql.NullFloat64
+	flushLag  sql.NullFloat64
+	replayLag sql.NullFloat64
 }
 
 func (rs *replicationStatus) primaryHostPort() (string, string, error) {
@@ -68,7 +151,7 @@ func (c *Collector) getReplicationStatus(ctx context.Context, version semver.Ver
 		return nil, fmt.Errorf("pg_is_in_recovery() returned null")
 	}
 
-	var fCurrentLsn, fReceiveLsn, fReplyLsn, fIsReplayPaused string
+	var fCurrentLsn, fReceiveLsn, fReplyLsn, fIsReplayPaused, standbysQuery, slotsQuery string
 	switch {
 	// the `pg_stat_wal_receiver` view has been introduced in 9.6
 	case semver.MustParseRange(">=9.6.0 <10.0.0")(version):
@@ -76,11 +159,34 @@ func (c *Collector) getReplicationStatus(ctx context.Context, version semver.Ver
 		fReceiveLsn = "pg_last_xlog_receive_location"
 		fReplyLsn = "pg_last_xlog_replay_location"
 		fIsReplayPaused = "pg_is_xlog_replay_paused"
+		standbysQuery = `SELECT pid, application_name, client_addr, state, sync_state,
+			%[1]s()-sent_location, %[1]s()-write_location, %[1]s()-flush_location, %[1]s()-replay_location,
+			null, null, null
+		FROM pg_stat_replication`
+		slotsQuery = `SELECT slot_name, slot_type, plugin, database, active,
+			%[1]s()-restart_lsn, %[1]s()-confirmed_flush_lsn, null, null
+		FROM pg_replication_slots`
 	case semver.MustParseRange(">=10.0.0")(version):
 		fCurrentLsn = "pg_current_wal_lsn"
 		fReceiveLsn = "pg_last_wal_receive_lsn"
 		fReplyLsn = "pg_last_wal_replay_lsn"
 		fIsReplayPaused = "pg_is_wal_replay_paused"
+		// the time lags become NULL once the standby has caught up with an idle server
+		standbysQuery = `SELECT pid, application_name, client_addr, state, sync_state,
+			%[1]s()-sent_lsn, %[1]s()-write_lsn, %[1]s()-flush_lsn, %[1]s()-replay_lsn,
+			CASE WHEN state = 'streaming' THEN coalesce(extract(epoch from write_lag), 0) END,
+			CASE WHEN state = 'streaming' THEN coalesce(extract(epoch from flush_lag), 0) END,
+			CASE WHEN state = 'streaming' THEN coalesce(extract(epoch from replay_lag), 0) END
+		FROM pg_stat_replication`
+		slotsQuery = `SELECT slot_name, slot_type, plugin, database, active,
+			%[1]s()-restart_lsn, %[1]s()-confirmed_flush_lsn, null, null
+		FROM pg_replication_slots`
+		// `wal_status` and `safe_wal_size` have been introduced in 13
+		if semver.MustParseRange(">=13.0.0")(version) {
+			slotsQuery = `SELECT slot_name, slot_type, plugin, database, active,
+				%[1]s()-restart_lsn, %[1]s()-confirmed_flush_lsn, wal_status, safe_wal_size
+			FROM pg_replication_slots`
+		}
 	default:
 		return nil, fmt.Errorf("postgres version %s is not supported", version)
 	}
@@ -92,7 +198,25 @@ func (c *Collector) getReplicationStatus(ctx context.Context, version semver.Ver
 			&rs.receiveLsn, &rs.replyLsn, &rs.isReplayPaused); err != nil {
 			return nil, err
 		}
-		if err := c.db.QueryRowContext(ctx, `SELECT count(1) FROM pg_stat_wal_receiver`).Scan(&rs.walReceiverStatus); err != nil {
+		// if the standby has replayed all the received WAL, it isn't lagging even if the primary has been idle for a while
+		if err := c.db.QueryRowContext(ctx, fmt.Sprintf(
+			`SELECT CASE WHEN %s() = %s() THEN 0 ELSE extract(epoch from now() - pg_last_xact_replay_timestamp()) END`, fReceiveLsn, fReplyLsn)).Scan(
+			&rs.replayLag); err != nil {
+			return nil, err
+		}
+		wr := &rs.walReceiver
+		err := c.db.QueryRowContext(ctx, `
+			SELECT status, received_tli, extract(epoch from last_msg_send_time), extract(epoch from last_msg_receipt_time),
+				latest_end_lsn-'0/0', extract(epoch from latest_end_time), slot_name
+			FROM pg_stat_wal_receiver`).Scan(
+			&wr.status, &wr.receivedTimeline, &wr.lastMsgSendTime, &wr.lastMsgReceiptTime,
+			&wr.latestEndLsn, &wr.latestEndTime, &wr.slotName)
+		switch {
+		case err == nil:
+			rs.walReceiverStatus = 1
+		case errors.Is(err, sql.ErrNoRows):
+			rs.walReceiverStatus = 0
+		default:
 			return nil, err
 		}
 		if err := c.db.QueryRowContext(ctx, `SELECT setting FROM pg_settings WHERE name='primary_conninfo'`).Scan(&rs.primaryConnectionInfo); err != nil {
@@ -105,5 +229,187 @@ func (c *Collector) getReplicationStatus(ctx context.Context, version semver.Ver
 			return nil, err
 		}
 	}
+
+	// a standby can have cascading standbys, their lag is calculated relative to the received WAL
+	lsnFunc := fCurrentLsn
+	if rs.isInRecovery {
+		lsnFunc = fReceiveLsn
+	}
+	var err error
+	if rs.standbys, err = c.getStandbys(ctx, fmt.Sprintf(standbysQuery, lsnFunc)); err != nil {
+		rs.errors = append(rs.errors, err)
+	}
+	if c.options.collector(collectorReplicationSlots).enabled() {
+		if rs.slots, err = c.getReplicationSlots(ctx, fmt.Sprintf(slotsQuery, lsnFunc)); err != nil {
+			rs.errors = append(rs.errors, err)
+		}
+	}
+	if c.options.collector(collectorArchiver).enabled() {
+		if rs.archiver, err = c.getArchiverStatus(ctx, version); err != nil {
+			rs.errors = append(rs.errors, err)
+		}
+	}
+	// logical replication has been introduced in 10;
+	// the publications are collected per database along with the other per-database collectors
+	if c.options.collector(collectorLogicalReplication).enabled() && semver.MustParseRange(">=10.0.0")(version) {
+		if rs.subscriptions, err = c.getSubscriptions(ctx, version); err != nil {
+			rs.errors = append(rs.errors, err)
+		}
+	}
 	return rs, nil
 }
+
+func (c *Collector) getArchiverStatus(ctx context.Context, version semver.Version) (*archiverStatus, error) {
+	as := &archiverStatus{}
+	err := c.db.QueryRowContext(ctx, `
+		SELECT archived_count, last_archived_wal, extract(epoch from last_archived_time),
+			failed_count, last_failed_wal, extract(epoch from last_failed_time),
+			pg_size_bytes(current_setting('wal_segment_size'))
+		FROM pg_stat_archiver`).Scan(
+		&as.archivedCount, &as.lastArchivedWal, &as.lastArchivedTime,
+		&as.failedCount, &as.lastFailedWal, &as.lastFailedTime,
+		&as.walSegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	// pg_ls_archive_statusdir() has been introduced in 12
+	if semver.MustParseRange(">=12.0.0")(version) {
+		err = c.db.QueryRowContext(ctx, `
+			SELECT count(1), coalesce(extract(epoch from now() - min(modification)), 0)
+			FROM pg_ls_archive_statusdir() WHERE name ~ '[.]ready$'`).Scan(&as.readyFiles, &as.lag)
+		if err != nil { // e.g., the role isn't a member of pg_monitor
+			return as, err
+		}
+	}
+	return as, nil
+}
+
+func (c *Collector) getReplicationSlots(ctx context.Context, query string) ([]replicationSlot, error) {
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []replicationSlot
+	for rows.Next() {
+		var s replicationSlot
+		err := rows.Scan(
+			&s.name, &s.slotType, &s.plugin, &s.database, &s.active,
+			&s.retainedWalBytes, &s.confirmedFlushLagBytes, &s.walStatus, &s.safeWalSize,
+		)
+		if err != nil {
+			c.logger.Warning("failed to scan pg_replication_slots row:", err)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (c *Collector) walReceiverMetrics(ch chan<- prometheus.Metric, wr walReceiver) {
+	ch <- gauge(dWalReceiverInfo, 1, wr.status.String, wr.slotName.String)
+	gaugeIfValid(ch, dWalReceiverTimeline, wr.receivedTimeline)
+	if wr.lastMsgSendTime.Valid {
+		ch <- gauge(dWalReceiverLastMsgSendTime, wr.lastMsgSendTime.Float64)
+	}
+	if wr.lastMsgReceiptTime.Valid {
+		ch <- gauge(dWalReceiverLastMsgReceiptTime, wr.lastMsgReceiptTime.Float64)
+	}
+	counterIfValid(ch, dWalReceiverLatestEndLsn, wr.latestEndLsn)
+	if wr.latestEndTime.Valid {
+		ch <- gauge(dWalReceiverLatestEndTime, wr.latestEndTime.Float64)
+	}
+}
+
+func (c *Collector) archiverMetrics(ch chan<- prometheus.Metric, as *archiverStatus) {
+	if as == nil {
+		return
+	}
+	counterIfValid(ch, dArchiverArchived, as.archivedCount)
+	counterIfValid(ch, dArchiverFailed, as.failedCount)
+	if as.lastArchivedTime.Valid {
+		ch <- gauge(dArchiverLastArchivedTime, as.lastArchivedTime.Float64)
+	}
+	if as.lastFailedTime.Valid {
+		ch <- gauge(dArchiverLastFailedTime, as.lastFailedTime.Float64)
+	}
+	if lsn, ok := walFileLsn(as.lastArchivedWal.String, as.walSegmentSize.Int64); ok {
+		ch <- counter(dArchiverLastArchivedLsn, float64(lsn))
+	}
+	if lsn, ok := walFileLsn(as.lastFailedWal.String, as.walSegmentSize.Int64); ok {
+		ch <- counter(dArchiverLastFailedLsn, float64(lsn))
+	}
+	gaugeIfValid(ch, dArchiverReadyFiles, as.readyFiles)
+	if as.lag.Valid {
+		ch <- gauge(dArchiverLag, as.lag.Float64)
+	}
+}
+
+func (c *Collector) replicationSlotMetrics(ch chan<- prometheus.Metric, slots []replicationSlot) {
+	for _, s := range slots {
+		name := s.name.String
+		active := 0.
+		if s.active.Bool {
+			active = 1
+		}
+		ch <- gauge(dReplicationSlotActive, active, name, s.slotType.String, s.plugin.String, s.database.String)
+		gaugeIfValid(ch, dReplicationSlotRetainedWal, s.retainedWalBytes, name)
+		gaugeIfValid(ch, dReplicationSlotConfirmedFlushLag, s.confirmedFlushLagBytes, name)
+		if s.walStatus.Valid {
+			ch <- gauge(dReplicationSlotWalStatus, 1, name, s.walStatus.String)
+		}
+		gaugeIfValid(ch, dReplicationSlotSafeWalSize, s.safeWalSize, name)
+	}
+}
+
+func (c *Collector) getStandbys(ctx context.Context, query string) ([]standbyStatus, error) {
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []standbyStatus
+	for rows.Next() {
+		var s standbyStatus
+		err := rows.Scan(
+			&s.pid, &s.applicationName, &s.clientAddr, &s.state, &s.syncState,
+			&s.sentLagBytes, &s.writeLagBytes, &s.flushLagBytes, &s.replayLagBytes,
+			&s.writeLag, &s.flushLag, &s.replayLag,
+		)
+		if err != nil {
+			c.logger.Warning("failed to scan pg_stat_replication row:", err)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// standbyMetrics identifies a standby by its application name and client address, so its series survive reconnects.
+// The WAL sender pid is added only to tell apart standbys sharing both, e.g., the default `walreceiver` name on the same host.
+func (c *Collector) standbyMetrics(ch chan<- prometheus.Metric, standbys []standbyStatus) {
+	type standbyKey struct{ name, addr string }
+	count := map[standbyKey]int{}
+	for _, s := range standbys {
+		count[standbyKey{name: s.applicationName.String, addr: s.clientAddr.String}]++
+	}
+	for _, s := range standbys {
+		name, addr := s.applicationName.String, s.clientAddr.String
+		pid := ""
+		if count[standbyKey{name: name, addr: addr}] > 1 {
+			pid = strconv.FormatInt(s.pid.Int64, 10)
+		}
+		ch <- gauge(dReplicationStandbyStatus, 1, name, addr, pid, s.state.String, s.syncState.String)
+		gaugeIfValid(ch, dReplicationStandbyLagBytes, s.sentLagBytes, name, addr, pid, "sent")
+		gaugeIfValid(ch, dReplicationStandbyLagBytes, s.writeLagBytes, name, addr, pid, "write")
+		gaugeIfValid(ch, dReplicationStandbyLagBytes, s.flushLagBytes, name, addr, pid, "flush")
+		gaugeIfValid(ch, dReplicationStandbyLagBytes, s.replayLagBytes, name, addr, pid, "replay")
+		for stage, lag := range map[string]sql.NullFloat64{"write": s.writeLag, "flush": s.flushLag, "replay": s.replayLag} {
+			if lag.Valid {
+				ch <- gauge(dReplicationStandbyLagSeconds, lag.Float64, name, addr, pid, stage)
+			}
+		}
+	}
+}
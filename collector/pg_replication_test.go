@@ -1,8 +1,13 @@
 package collector
 
 import (
-	"github.com/stretchr/testify/assert"
+	"database/sql"
+	"strings"
 	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 )
 
 func Test_parsePrimaryConnectionInfo(t *testing.T) {
@@ -29,3 +34,102 @@ func Test_parsePrimaryConnectionInfo(t *testing.T) {
 	check("postgresql://other@localhost/otherdb?connect_timeout=10&application_name=myapp", "localhost", "")
 	check("postgresql://[2001:db8::1234]/database", "2001:db8::1234", "")
 }
+
+func Test_walFileLsn(t *testing.T) {
+	check := func(name string, segmentSize int64, lsn int64, ok bool) {
+		l, o := walFileLsn(name, segmentSize)
+		assert.Equal(t, ok, o)
+		assert.Equal(t, lsn, l)
+	}
+	const mb16 = 16 * 1024 * 1024
+	check("000000010000000000000001", mb16, 0x1000000, true)
+	check("000000010000000A000000FF", mb16, 0xAFF000000, true)
+	check("00000002000000010000003A", 64*1024*1024, 0x1E8000000, true)
+
+	check("00000002.history", mb16, 0, false)
+	check("000000010000000000000001.00000028.backup", mb16, 0, false)
+	check("000000010000000000000001.partial", mb16, 0, false)
+	check("000000010000000000000001", 0, 0, false)
+	check("", mb16, 0, false)
+}
+
+func TestStandbyMetrics(t *testing.T) {
+	standby := func(pid int64, name, addr string) standbyStatus {
+		return standbyStatus{
+			pid: sql.NullInt64{Int64: pid, Valid: true}, applicationName: nullString(name), clientAddr: nullString(addr),
+			state: nullString("streaming"), syncState: nullString("async"),
+		}
+	}
+	standbys := []standbyStatus{
+		standby(100, "replica1", "10.0.0.1"),
+		standby(101, "walreceiver", "10.0.0.2"),
+		standby(102, "walreceiver", "10.0.0.2"),
+	}
+	c := &Collector{}
+	metrics := collectFunc(func(ch chan<- prometheus.Metric) { c.standbyMetrics(ch, standbys) })
+
+	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP pg_replication_standby_status Standby connected to the WAL sender: 1 with the current state and synchronization state
+# TYPE pg_replication_standby_status gauge
+pg_replication_standby_status{application_name="replica1",client_addr="10.0.0.1",pid="",state="streaming",sync_state="async"} 1
+pg_replication_standby_status{application_name="walreceiver",client_addr="10.0.0.2",pid="101",state="streaming",sync_state="async"} 1
+pg_replication_standby_status{application_name="walreceiver",client_addr="10.0.0.2",pid="102",state="streaming",sync_state="async"} 1
+`)))
+}
+
+func TestStandbyReplicationMetrics(t *testing.T) {
+	c := &Collector{replicationStatus: &replicationStatus{
+		isInRecovery: true,
+		receiveLsn:   nullInt64(2000),
+		replyLsn:     nullInt64(2000),
+		// all the received WAL has been replayed, so the standby isn't lagging even if the primary is idle
+		replayLag:             nullFloat64(0),
+		walReceiverStatus:     1,
+		primaryConnectionInfo: "host=10.0.0.1 port=5432",
+		walReceiver: walReceiver{
+			status:             nullString("streaming"),
+			receivedTimeline:   nullInt64(1),
+			lastMsgSendTime:    nullFloat64(1700000000),
+			lastMsgReceiptTime: nullFloat64(1700000001),
+			latestEndLsn:       nullInt64(2000),
+			latestEndTime:      nullFloat64(1699999990),
+			slotName:           nullString("replica1"),
+		},
+	}}
+
+	assert.NoError(t, testutil.CollectAndCompare(collectFunc(c.replicationMetrics), strings.NewReader(`
+# HELP pg_wal_receive_lsn WAL sequence number that has been received and synced to disk by streaming replication
+# TYPE pg_wal_receive_lsn counter
+pg_wal_receive_lsn 2000
+# HELP pg_wal_receiver_info WAL receiver process status and the replication slot it uses
+# TYPE pg_wal_receiver_info gauge
+pg_wal_receiver_info{slot_name="replica1",status="streaming"} 1
+# HELP pg_wal_receiver_last_msg_receipt_timestamp_seconds Receipt time of the last message received from the WAL sender
+# TYPE pg_wal_receiver_last_msg_receipt_timestamp_seconds gauge
+pg_wal_receiver_last_msg_receipt_timestamp_seconds 1.700000001e+09
+# HELP pg_wal_receiver_last_msg_send_timestamp_seconds Send time of the last message received from the WAL sender
+# TYPE pg_wal_receiver_last_msg_send_timestamp_seconds gauge
+pg_wal_receiver_last_msg_send_timestamp_seconds 1.7e+09
+# HELP pg_wal_receiver_latest_end_lsn Last WAL location reported to the WAL sender
+# TYPE pg_wal_receiver_latest_end_lsn counter
+pg_wal_receiver_latest_end_lsn 2000
+# HELP pg_wal_receiver_latest_end_timestamp_seconds Time of the last WAL location reported to the WAL sender
+# TYPE pg_wal_receiver_latest_end_timestamp_seconds gauge
+pg_wal_receiver_latest_end_timestamp_seconds 1.69999999e+09
+# HELP pg_wal_receiver_status WAL receiver status: 1 if the receiver is connected, otherwise 0
+# TYPE pg_wal_receiver_status gauge
+pg_wal_receiver_status{sender_host="10.0.0.1",sender_port="5432"} 1
+# HELP pg_wal_receiver_timeline Timeline number of the last WAL location received and flushed to disk
+# TYPE pg_wal_receiver_timeline gauge
+pg_wal_receiver_timeline 1
+# HELP pg_wal_replay_lag_seconds Time elapsed since the last transaction replayed during recovery was committed on the primary, 0 if all the received WAL has been replayed
+# TYPE pg_wal_replay_lag_seconds gauge
+pg_wal_replay_lag_seconds 0
+# HELP pg_wal_replay_paused Whether WAL replay paused or not
+# TYPE pg_wal_replay_paused gauge
+pg_wal_replay_paused 0
+# HELP pg_wal_reply_lsn WAL sequence number that has been replayed during recovery
+# TYPE pg_wal_reply_lsn counter
+pg_wal_reply_lsn 2000
+`)))
+}
@@ -0,0 +1,139 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type namedSize struct {
+	name string
+	size sql.NullInt64
+}
+
+type relationSize struct {
+	db      string
+	schema  sql.NullString
+	table   sql.NullString
+	heap    sql.NullInt64
+	indexes sql.NullInt64
+	toast   sql.NullInt64
+}
+
+func (s relationSize) total() int64 {
+	return s.heap.Int64 + s.indexes.Int64 + s.toast.Int64
+}
+
+type sizes struct {
+	databases   []namedSize
+	tablespaces []namedSize
+	relations   []relationSize
+}
+
+func (c *Collector) getSizes(ctx context.Context, topN int) (*sizes, error) {
+	res := &sizes{}
+	// the size functions may take a while on large clusters, so they are executed through a separate pool
+	// to keep the main connection available for the snapshots and probes
+	var current string
+	if err := c.db.QueryRowContext(ctx, `SELECT current_database()`).Scan(&current); err != nil {
+		return res, err
+	}
+	mainDB, err := c.database(current)
+	if err != nil {
+		return res, err
+	}
+	res.databases, err = c.queryNamedSizes(ctx, mainDB, `SELECT datname, pg_database_size(datname) FROM pg_database WHERE NOT datistemplate AND datallowconn`)
+	if err != nil {
+		return res, err
+	}
+	res.tablespaces, err = c.queryNamedSizes(ctx, mainDB, `SELECT spcname, pg_tablespace_size(oid) FROM pg_tablespace`)
+	if err != nil {
+		return res, err
+	}
+	err = c.forEachDatabase(ctx, func(name string, db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, `
+			SELECT nspname, relname, total - indexes - toast, indexes, toast
+			FROM (
+				SELECT n.nspname, c.relname, pg_total_relation_size(c.oid) AS total, pg_indexes_size(c.oid) AS indexes,
+					CASE WHEN c.reltoastrelid = 0 THEN 0 ELSE pg_total_relation_size(c.reltoastrelid) END AS toast
+				FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+				WHERE c.relkind IN ('r','m') AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname !~ '^pg_toast'
+				ORDER BY 3 DESC NULLS LAST
+				LIMIT $1
+			) s`, topN)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			s := relationSize{db: name}
+			if err := rows.Scan(&s.schema, &s.table, &s.heap, &s.indexes, &s.toast); err != nil {
+				c.logger.Warning("failed to scan relation size row:", err)
+				continue
+			}
+			res.relations = append(res.relations, s)
+		}
+		return rows.Err()
+	})
+	res.relations = topRelationSizes(res.relations, topN)
+	return res, err
+}
+
+func (c *Collector) queryNamedSizes(ctx context.Context, db *sql.DB, query string) ([]namedSize, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []namedSize
+	for rows.Next() {
+		var s namedSize
+		if err := rows.Scan(&s.name, &s.size); err != nil {
+			c.logger.Warning("failed to scan size row:", err)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func topRelationSizes(all []relationSize, n int) []relationSize {
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].total() > all[j].total()
+	})
+	if n > len(all) {
+		n = len(all)
+	}
+	return all[:n]
+}
+
+func (c *Collector) updateSizes() {
+	o := c.options.collector(collectorSizes)
+	ctx, cancelFunc := context.WithTimeout(c.ctx, o.timeout())
+	defer cancelFunc()
+	s, err := c.getSizes(ctx, o.TopN)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.sizes = s
+	c.periodicErrors[collectorSizes] = err
+}
+
+func (c *Collector) sizeMetrics(ch chan<- prometheus.Metric) {
+	if c.sizes == nil {
+		return
+	}
+	for _, s := range c.sizes.databases {
+		gaugeIfValid(ch, dDbSize, s.size, s.name)
+	}
+	for _, s := range c.sizes.tablespaces {
+		gaugeIfValid(ch, dTablespaceSize, s.size, s.name)
+	}
+	for _, s := range c.sizes.relations {
+		db, schema, table := s.db, s.schema.String, s.table.String
+		gaugeIfValid(ch, dTableSize, s.heap, db, schema, table, "heap")
+		gaugeIfValid(ch, dTableSize, s.indexes, db, schema, table, "index")
+		gaugeIfValid(ch, dTableSize, s.toast, db, schema, table, "toast")
+	}
+}
@@ -9,17 +9,27 @@ import (
 
 	"github.com/blang/semver"
 	"github.com/coroot/coroot-pg-agent/obfuscate"
+	"github.com/lib/pq"
 )
 
 type Connection struct {
-	DB            sql.NullString
-	User          sql.NullString
-	Query         sql.NullString
-	State         sql.NullString
-	QueryStart    sql.NullTime
-	BackendType   sql.NullString
-	WaitEventType sql.NullString
-	BlockingPid   sql.NullInt32
+	DB              sql.NullString
+	User            sql.NullString
+	Query           sql.NullString
+	State           sql.NullString
+	QueryStart      sql.NullTime
+	StateChange     sql.NullTime
+	BackendType     sql.NullString
+	WaitEventType   sql.NullString
+	WaitEvent       sql.NullString
+	ApplicationName sql.NullString
+	XactStart       sql.NullTime
+	BackendStart    sql.NullTime
+	ClientAddr      sql.NullString
+	ClientHostname  sql.NullString
+	BlockingPids    []int64
+	XminAge         sql.NullInt64
+	XidAge          sql.NullInt64
 }
 
 func (c Connection) IsClientBackend() bool {
@@ -40,14 +50,21 @@ func (c *Collector) getPgStatActivity(ctx context.Context, version semver.Versio
 	var query string
 	switch {
 	case semver.MustParseRange(">=9.3.0 <9.6.0")(version):
-		query = "SELECT s.pid, s.datname, s.usename, LEFT(s.query, %d), s.state, now(), s.query_start, s.waiting, null, null, null"
+		query = "SELECT s.pid, s.datname, s.usename, LEFT(s.query, %d), s.state, now(), s.query_start, s.state_change, s.waiting, null, null, null, null"
 	case semver.MustParseRange(">=9.6.0 <10.0.0")(version):
-		query = "SELECT s.pid, s.datname, s.usename, LEFT(s.query, %d), s.state, now(), s.query_start, null, s.wait_event_type, null, (pg_blocking_pids(s.pid))[1]"
+		query = "SELECT s.pid, s.datname, s.usename, LEFT(s.query, %d), s.state, now(), s.query_start, s.state_change, null, s.wait_event_type, s.wait_event, null, pg_blocking_pids(s.pid)"
 	case semver.MustParseRange(">=10.0.0")(version):
-		query = "SELECT s.pid, s.datname, s.usename, LEFT(s.query, %d), s.state, now(), s.query_start, null, s.wait_event_type, s.backend_type, (pg_blocking_pids(s.pid))[1]"
+		query = "SELECT s.pid, s.datname, s.usename, LEFT(s.query, %d), s.state, now(), s.query_start, s.state_change, null, s.wait_event_type, s.wait_event, s.backend_type, pg_blocking_pids(s.pid)"
 	default:
 		return nil, fmt.Errorf("postgres version %s is not supported", version)
 	}
+	// `backend_xmin` and `backend_xid` have been introduced in 9.4
+	if semver.MustParseRange(">=9.4.0")(version) {
+		query += ", age(s.backend_xmin), age(s.backend_xid)"
+	} else {
+		query += ", null, null"
+	}
+	query += ", s.application_name, s.xact_start, s.backend_start, host(s.client_addr), s.client_hostname"
 	query += " FROM pg_stat_activity s JOIN pg_database d ON s.datid = d.oid AND NOT d.datistemplate"
 	rows, err := c.db.QueryContext(ctx, fmt.Sprintf(query, querySizeLimit))
 	if err != nil {
@@ -62,8 +79,9 @@ func (c *Collector) getPgStatActivity(ctx context.Context, version semver.Versio
 			oldStyleWaiting sql.NullBool
 		)
 		err := rows.Scan(
-			&pid, &conn.DB, &conn.User, &conn.Query, &conn.State, &snapshot.ts, &conn.QueryStart,
-			&oldStyleWaiting, &conn.WaitEventType, &conn.BackendType, &conn.BlockingPid,
+			&pid, &conn.DB, &conn.User, &conn.Query, &conn.State, &snapshot.ts, &conn.QueryStart, &conn.StateChange,
+			&oldStyleWaiting, &conn.WaitEventType, &conn.WaitEvent, &conn.BackendType, pq.Array(&conn.BlockingPids),
+			&conn.XminAge, &conn.XidAge, &conn.ApplicationName, &conn.XactStart, &conn.BackendStart, &conn.ClientAddr, &conn.ClientHostname,
 		)
 		if err != nil {
 			c.logger.Warning("failed to scan pg_stat_activity row:", err)
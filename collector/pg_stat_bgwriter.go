@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type bgwriterStat struct {
+	checkpointsTimed     sql.NullInt64
+	checkpointsRequested sql.NullInt64
+	checkpointWriteTime  sql.NullFloat64
+	checkpointSyncTime   sql.NullFloat64
+	buffersCheckpoint    sql.NullInt64
+	buffersClean         sql.NullInt64
+	maxwrittenClean      sql.NullInt64
+	buffersBackend       sql.NullInt64
+	buffersBackendFsync  sql.NullInt64
+	buffersAlloc         sql.NullInt64
+}
+
+func (c *Collector) getBgwriterStat(ctx context.Context, version semver.Version) (*bgwriterStat, error) {
+	var query string
+	switch {
+	case semver.MustParseRange(">=9.2.0 <17.0.0")(version):
+		query = `SELECT checkpoints_timed, checkpoints_req, checkpoint_write_time, checkpoint_sync_time, buffers_checkpoint,
+			buffers_clean, maxwritten_clean, buffers_backend, buffers_backend_fsync, buffers_alloc
+		FROM pg_stat_bgwriter`
+	// the checkpointer stats have been moved to `pg_stat_checkpointer`, the backend ones are available only in `pg_stat_io`
+	case semver.MustParseRange(">=17.0.0")(version):
+		query = `SELECT c.num_timed, c.num_requested, c.write_time, c.sync_time, c.buffers_written,
+			b.buffers_clean, b.maxwritten_clean, null, null, b.buffers_alloc
+		FROM pg_stat_checkpointer c, pg_stat_bgwriter b`
+	default:
+		return nil, fmt.Errorf("postgres version %s is not supported", version)
+	}
+	s := &bgwriterStat{}
+	err := c.db.QueryRowContext(ctx, query).Scan(
+		&s.checkpointsTimed, &s.checkpointsRequested, &s.checkpointWriteTime, &s.checkpointSyncTime, &s.buffersCheckpoint,
+		&s.buffersClean, &s.maxwrittenClean, &s.buffersBackend, &s.buffersBackendFsync, &s.buffersAlloc,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *Collector) bgwriterMetrics(ch chan<- prometheus.Metric) {
+	s := c.bgwriter
+	if s == nil {
+		return
+	}
+	counterIfValid(ch, dCheckpoints, s.checkpointsTimed, "timed")
+	counterIfValid(ch, dCheckpoints, s.checkpointsRequested, "requested")
+	if s.checkpointWriteTime.Valid {
+		ch <- counter(dCheckpointTime, s.checkpointWriteTime.Float64/1000, "write")
+	}
+	if s.checkpointSyncTime.Valid {
+		ch <- counter(dCheckpointTime, s.checkpointSyncTime.Float64/1000, "sync")
+	}
+	counterIfValid(ch, dBuffersWritten, s.buffersCheckpoint, "checkpointer")
+	counterIfValid(ch, dBuffersWritten, s.buffersClean, "bgwriter")
+	counterIfValid(ch, dBuffersWritten, s.buffersBackend, "backend")
+	counterIfValid(ch, dBgwriterMaxwrittenClean, s.maxwrittenClean)
+	counterIfValid(ch, dBuffersBackendFsync, s.buffersBackendFsync)
+	counterIfValid(ch, dBuffersAllocated, s.buffersAlloc)
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBgwriterMetrics(t *testing.T) {
+	// in 17+, the buffers written and fsynced by backends are available only in pg_stat_io
+	c := &Collector{
+		bgwriter: &bgwriterStat{
+			checkpointsTimed:     nullInt64(10),
+			checkpointsRequested: nullInt64(2),
+			checkpointWriteTime:  nullFloat64(1500),
+			checkpointSyncTime:   nullFloat64(250),
+			buffersCheckpoint:    nullInt64(1000),
+			buffersClean:         nullInt64(200),
+			maxwrittenClean:      nullInt64(3),
+			buffersAlloc:         nullInt64(5000),
+		},
+		wal: &walStat{
+			records:     nullInt64(100),
+			fpi:         nullInt64(10),
+			bytes:       nullFloat64(65536),
+			buffersFull: sql.NullInt64{},
+		},
+	}
+	metrics := collectFunc(func(ch chan<- prometheus.Metric) {
+		c.bgwriterMetrics(ch)
+		c.walMetrics(ch)
+	})
+
+	assert.NoError(t, testutil.CollectAndCompare(metrics, strings.NewReader(`
+# HELP pg_bgwriter_maxwritten_clean_total Number of times the background writer stopped a cleaning scan because it had written too many buffers
+# TYPE pg_bgwriter_maxwritten_clean_total counter
+pg_bgwriter_maxwritten_clean_total 3
+# HELP pg_buffers_allocated_total Number of buffers allocated
+# TYPE pg_buffers_allocated_total counter
+pg_buffers_allocated_total 5000
+# HELP pg_buffers_written_total Number of buffers written by the checkpointer, the background writer and backends
+# TYPE pg_buffers_written_total counter
+pg_buffers_written_total{by="bgwriter"} 200
+pg_buffers_written_total{by="checkpointer"} 1000
+# HELP pg_checkpoint_time_seconds_total Time spent in the portion of checkpoint processing where files are written or synchronized to disk
+# TYPE pg_checkpoint_time_seconds_total counter
+pg_checkpoint_time_seconds_total{stage="sync"} 0.25
+pg_checkpoint_time_seconds_total{stage="write"} 1.5
+# HELP pg_checkpoints_total Number of scheduled and requested checkpoints that have been performed
+# TYPE pg_checkpoints_total counter
+pg_checkpoints_total{type="requested"} 2
+pg_checkpoints_total{type="timed"} 10
+# HELP pg_wal_bytes_total Amount of WAL generated in bytes
+# TYPE pg_wal_bytes_total counter
+pg_wal_bytes_total 65536
+# HELP pg_wal_fpi_total Number of WAL full page images generated
+# TYPE pg_wal_fpi_total counter
+pg_wal_fpi_total 10
+# HELP pg_wal_records_total Number of WAL records generated
+# TYPE pg_wal_records_total counter
+pg_wal_records_total 100
+`)))
+}
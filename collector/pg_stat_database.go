@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type dbStat struct {
+	db                sql.NullString
+	commits           sql.NullInt64
+	rollbacks         sql.NullInt64
+	blocksRead        sql.NullInt64
+	blocksHit         sql.NullInt64
+	tuplesReturned    sql.NullInt64
+	tuplesFetched     sql.NullInt64
+	tuplesInserted    sql.NullInt64
+	tuplesUpdated     sql.NullInt64
+	tuplesDeleted     sql.NullInt64
+	tempFiles         sql.NullInt64
+	tempBytes         sql.NullInt64
+	deadlocks         sql.NullInt64
+	conflicts         sql.NullInt64
+	checksumFailures  sql.NullInt64
+	sessionTime       sql.NullFloat64
+	activeTime        sql.NullFloat64
+	idleInTxTime      sql.NullFloat64
+	sessions          sql.NullInt64
+	sessionsAbandoned sql.NullInt64
+	sessionsFatal     sql.NullInt64
+	sessionsKilled    sql.NullInt64
+}
+
+func (c *Collector) getStatDatabase(ctx context.Context, version semver.Version) ([]dbStat, error) {
+	query := `SELECT s.datname, s.xact_commit, s.xact_rollback, s.blks_read, s.blks_hit,
+		s.tup_returned, s.tup_fetched, s.tup_inserted, s.tup_updated, s.tup_deleted,
+		s.temp_files, s.temp_bytes, s.deadlocks, s.conflicts`
+	switch {
+	case semver.MustParseRange(">=9.2.0 <12.0.0")(version):
+		query += `, null, null, null, null, null, null, null, null`
+	case semver.MustParseRange(">=12.0.0 <14.0.0")(version):
+		query += `, s.checksum_failures, null, null, null, null, null, null, null`
+	case semver.MustParseRange(">=14.0.0")(version):
+		query += `, s.checksum_failures, s.session_time, s.active_time, s.idle_in_transaction_time,
+		s.sessions, s.sessions_abandoned, s.sessions_fatal, s.sessions_killed`
+	default:
+		return nil, fmt.Errorf("postgres version %s is not supported", version)
+	}
+	query += ` FROM pg_stat_database s JOIN pg_database d ON d.oid = s.datid AND NOT d.datistemplate`
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []dbStat
+	for rows.Next() {
+		var s dbStat
+		err := rows.Scan(
+			&s.db, &s.commits, &s.rollbacks, &s.blocksRead, &s.blocksHit,
+			&s.tuplesReturned, &s.tuplesFetched, &s.tuplesInserted, &s.tuplesUpdated, &s.tuplesDeleted,
+			&s.tempFiles, &s.tempBytes, &s.deadlocks, &s.conflicts,
+			&s.checksumFailures, &s.sessionTime, &s.activeTime, &s.idleInTxTime,
+			&s.sessions, &s.sessionsAbandoned, &s.sessionsFatal, &s.sessionsKilled,
+		)
+		if err != nil {
+			c.logger.Warning("failed to scan pg_stat_database row:", err)
+			continue
+		}
+		if s.db.String == "" {
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (c *Collector) statDatabaseMetrics(ch chan<- prometheus.Metric) {
+	counterMs := func(desc *prometheus.Desc, v sql.NullFloat64, labels ...string) {
+		if v.Valid {
+			ch <- counter(desc, v.Float64/1000, labels...)
+		}
+	}
+	for _, s := range c.statDatabase {
+		db := s.db.String
+		counterIfValid(ch, dDbTransactions, s.commits, db, "commit")
+		counterIfValid(ch, dDbTransactions, s.rollbacks, db, "rollback")
+		counterIfValid(ch, dDbBlocksRead, s.blocksRead, db)
+		counterIfValid(ch, dDbBlocksHit, s.blocksHit, db)
+		counterIfValid(ch, dDbTuples, s.tuplesReturned, db, "returned")
+		counterIfValid(ch, dDbTuples, s.tuplesFetched, db, "fetched")
+		counterIfValid(ch, dDbTuples, s.tuplesInserted, db, "inserted")
+		counterIfValid(ch, dDbTuples, s.tuplesUpdated, db, "updated")
+		counterIfValid(ch, dDbTuples, s.tuplesDeleted, db, "deleted")
+		counterIfValid(ch, dDbTempFiles, s.tempFiles, db)
+		counterIfValid(ch, dDbTempBytes, s.tempBytes, db)
+		counterIfValid(ch, dDbDeadlocks, s.deadlocks, db)
+		counterIfValid(ch, dDbConflicts, s.conflicts, db)
+		counterIfValid(ch, dDbChecksumFailures, s.checksumFailures, db)
+		counterMs(dDbSessionTime, s.sessionTime, db, "total")
+		counterMs(dDbSessionTime, s.activeTime, db, "active")
+		counterMs(dDbSessionTime, s.idleInTxTime, db, "idle in transaction")
+		counterIfValid(ch, dDbSessions, s.sessions, db)
+		counterIfValid(ch, dDbSessionsTerminated, s.sessionsAbandoned, db, "abandoned")
+		counterIfValid(ch, dDbSessionsTerminated, s.sessionsFatal, db, "fatal")
+		counterIfValid(ch, dDbSessionsTerminated, s.sessionsKilled, db, "killed")
+	}
+}
@@ -0,0 +1,57 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type ioStat struct {
+	backendType sql.NullString
+	object      sql.NullString
+	context     sql.NullString
+	reads       sql.NullInt64
+	writes      sql.NullInt64
+	extends     sql.NullInt64
+	hits        sql.NullInt64
+	evictions   sql.NullInt64
+	fsyncs      sql.NullInt64
+}
+
+func (c *Collector) getStatIO(ctx context.Context, version semver.Version) ([]ioStat, error) {
+	// the `pg_stat_io` view has been introduced in 16
+	if semver.MustParseRange("<16.0.0")(version) {
+		return nil, nil
+	}
+	rows, err := c.db.QueryContext(ctx, `SELECT backend_type, object, context, reads, writes, extends, hits, evictions, fsyncs FROM pg_stat_io`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []ioStat
+	for rows.Next() {
+		var s ioStat
+		if err := rows.Scan(&s.backendType, &s.object, &s.context, &s.reads, &s.writes, &s.extends, &s.hits, &s.evictions, &s.fsyncs); err != nil {
+			c.logger.Warning("failed to scan pg_stat_io row:", err)
+			continue
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+func (c *Collector) statIOMetrics(ch chan<- prometheus.Metric) {
+	for _, s := range c.statIO {
+		backendType, object, ioContext := s.backendType.String, s.object.String, s.context.String
+		// NULL means the operation isn't applicable to the backend type, object and context combination
+		counterIfValid(ch, dIOOperations, s.reads, backendType, object, ioContext, "read")
+		counterIfValid(ch, dIOOperations, s.writes, backendType, object, ioContext, "write")
+		counterIfValid(ch, dIOOperations, s.extends, backendType, object, ioContext, "extend")
+		counterIfValid(ch, dIOOperations, s.hits, backendType, object, ioContext, "hit")
+		counterIfValid(ch, dIOOperations, s.evictions, backendType, object, ioContext, "eviction")
+		counterIfValid(ch, dIOOperations, s.fsyncs, backendType, object, ioContext, "fsync")
+	}
+}
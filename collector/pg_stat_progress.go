@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Each query returns: pid, command, relation, phase, blocks total, blocks done, bytes total, bytes done.
+// The relation OIDs can only be resolved to names within the same database,
+// so the per-database views are queried through the connection to each database.
+var progressQueries = []struct {
+	versionRange string
+	query        string
+}{
+	{">=9.6.0", `
+		SELECT p.pid, CASE WHEN a.query LIKE 'autovacuum:%' THEN 'autovacuum' ELSE 'vacuum' END, p.relid::regclass::text, p.phase,
+			p.heap_blks_total, p.heap_blks_scanned, null::bigint, null::bigint
+		FROM pg_stat_progress_vacuum p LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database()`},
+	{">=13.0.0", `
+		SELECT p.pid, CASE WHEN a.query LIKE 'autovacuum:%' THEN 'autoanalyze' ELSE 'analyze' END, p.relid::regclass::text, p.phase,
+			p.sample_blks_total, p.sample_blks_scanned, null::bigint, null::bigint
+		FROM pg_stat_progress_analyze p LEFT JOIN pg_stat_activity a ON a.pid = p.pid
+		WHERE p.datname = current_database()`},
+	{">=12.0.0", `
+		SELECT p.pid, lower(p.command), p.relid::regclass::text, p.phase,
+			p.blocks_total, p.blocks_done, null::bigint, null::bigint
+		FROM pg_stat_progress_create_index p
+		WHERE p.datname = current_database()`},
+	{">=12.0.0", `
+		SELECT p.pid, lower(p.command), p.relid::regclass::text, p.phase,
+			p.heap_blks_total, p.heap_blks_scanned, null::bigint, null::bigint
+		FROM pg_stat_progress_cluster p
+		WHERE p.datname = current_database()`},
+	{">=14.0.0", `
+		SELECT p.pid, lower(p.command), CASE WHEN p.relid = 0 THEN '' ELSE p.relid::regclass::text END, '',
+			null::bigint, null::bigint, p.bytes_total, p.bytes_processed
+		FROM pg_stat_progress_copy p
+		WHERE p.datname = current_database()`},
+}
+
+type progress struct {
+	pid         int
+	db          string
+	user        sql.NullString
+	command     sql.NullString
+	relation    sql.NullString
+	phase       sql.NullString
+	blocksTotal sql.NullInt64
+	blocksDone  sql.NullInt64
+	bytesTotal  sql.NullInt64
+	bytesDone   sql.NullInt64
+	duration    sql.NullFloat64
+}
+
+func (c *Collector) getProgress(ctx context.Context, version semver.Version) ([]progress, error) {
+	var queries []string
+	for _, q := range progressQueries {
+		if semver.MustParseRange(q.versionRange)(version) {
+			queries = append(queries, q.query)
+		}
+	}
+	// the `pg_stat_progress_vacuum` view, the first of them, has been introduced in 9.6
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	query := `
+		SELECT p.*, a.usename, extract(epoch from now() - a.query_start)
+		FROM (` + strings.Join(queries, " UNION ALL ") + `) p(pid, command, relation, phase, blocks_total, blocks_done, bytes_total, bytes_done)
+		LEFT JOIN pg_stat_activity a ON a.pid = p.pid`
+
+	var res []progress
+	err := c.forEachDatabase(ctx, func(name string, db *sql.DB) error {
+		items, err := c.queryProgress(ctx, db, name, query)
+		res = append(res, items...)
+		return err
+	})
+
+	// the `pg_stat_progress_basebackup` view has been introduced in 13;
+	// it's server-wide, so it doesn't depend on the per-database queries
+	if semver.MustParseRange(">=13.0.0")(version) {
+		items, bbErr := c.queryProgress(ctx, c.db, "", `
+			SELECT p.pid, 'basebackup', '', p.phase, null::bigint, null::bigint, p.backup_total, p.backup_streamed,
+				a.usename, extract(epoch from now() - a.backend_start)
+			FROM pg_stat_progress_basebackup p LEFT JOIN pg_stat_activity a ON a.pid = p.pid`)
+		res = append(res, items...)
+		if bbErr != nil { // unlike the per-database errors, not logged by forEachDatabase
+			c.logger.Warning(bbErr)
+			if err == nil {
+				err = bbErr
+			}
+		}
+	}
+	return res, err
+}
+
+func (c *Collector) queryProgress(ctx context.Context, db *sql.DB, dbName string, query string) ([]progress, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []progress
+	for rows.Next() {
+		p := progress{db: dbName}
+		err := rows.Scan(
+			&p.pid, &p.command, &p.relation, &p.phase, &p.blocksTotal, &p.blocksDone, &p.bytesTotal, &p.bytesDone,
+			&p.user, &p.duration,
+		)
+		if err != nil {
+			c.logger.Warning("failed to scan progress row:", err)
+			continue
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+func (c *Collector) progressMetrics(ch chan<- prometheus.Metric) {
+	for _, p := range c.progress {
+		labels := []string{strconv.Itoa(p.pid), p.db, p.user.String, p.command.String, p.relation.String, p.phase.String}
+		gaugeIfValid(ch, dProgressBlocksTotal, p.blocksTotal, labels...)
+		gaugeIfValid(ch, dProgressBlocksDone, p.blocksDone, labels...)
+		gaugeIfValid(ch, dProgressBytesTotal, p.bytesTotal, labels...)
+		gaugeIfValid(ch, dProgressBytesDone, p.bytesDone, labels...)
+		if p.duration.Valid {
+			ch <- gauge(dProgressDuration, p.duration.Float64, labels...)
+		}
+	}
+}
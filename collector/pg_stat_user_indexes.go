@@ -0,0 +1,219 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type indexStat struct {
+	db     string
+	schema sql.NullString
+	table  sql.NullString
+	index  sql.NullString
+
+	scans       sql.NullInt64
+	tupRead     sql.NullInt64
+	tupFetch    sql.NullInt64
+	size        sql.NullInt64
+	isValid     bool
+	isUnique    bool
+	isPrimary   bool
+	isExclusion bool
+
+	method      string
+	columns     []string
+	opclasses   []string
+	collations  []string
+	predicate   sql.NullString
+	expressions sql.NullString
+
+	redundancy string
+	coveredBy  string
+}
+
+func (s indexStat) isUnused() bool {
+	return s.scans.Valid && s.scans.Int64 == 0 && !s.enforcesConstraint()
+}
+
+// enforcesConstraint reports whether the index is needed regardless of the scans: it backs a primary key, unique or exclusion constraint.
+func (s indexStat) enforcesConstraint() bool {
+	return s.isUnique || s.isPrimary || s.isExclusion
+}
+
+func (s indexStat) isFlagged() bool {
+	return s.isUnused() || !s.isValid || s.redundancy != ""
+}
+
+// covers reports whether the other index can serve all the lookups of s:
+// both indexes are on the same columns or the columns of s are a prefix of the other's ones.
+func (s indexStat) covers(other indexStat) (duplicate bool, overlapping bool) {
+	if s.db != other.db || s.schema != other.schema || s.table != other.table || s.index == other.index {
+		return false, false
+	}
+	if s.method != other.method || s.predicate != other.predicate || s.expressions != other.expressions {
+		return false, false
+	}
+	if len(s.columns) > len(other.columns) || len(s.opclasses) > len(other.opclasses) || len(s.collations) > len(other.collations) {
+		return false, false
+	}
+	for i := range s.columns {
+		if s.columns[i] != other.columns[i] {
+			return false, false
+		}
+	}
+	for i := range s.opclasses {
+		if s.opclasses[i] != other.opclasses[i] {
+			return false, false
+		}
+	}
+	for i := range s.collations {
+		if s.collations[i] != other.collations[i] {
+			return false, false
+		}
+	}
+	if len(s.columns) == len(other.columns) {
+		return true, false
+	}
+	// only B-tree indexes can be used for searching by a prefix of the key columns
+	return false, s.method == "btree"
+}
+
+// findRedundantIndexes marks duplicate indexes and the ones whose columns are a prefix of another index.
+// Of two duplicates, the one enforcing a constraint is kept, otherwise the one with the lesser name.
+// Unique and exclusion indexes are never considered overlapping since they enforce the constraint on their own columns.
+func findRedundantIndexes(indexes []indexStat) {
+	for i := range indexes {
+		idx := &indexes[i]
+		var coveredBy *indexStat
+		for j := range indexes {
+			other := &indexes[j]
+			duplicate, overlapping := idx.covers(*other)
+			switch {
+			case duplicate:
+				// exactly one of the duplicates is kept: primary keys over unique and exclusion indexes over the others
+				if idx.outranks(*other) || !other.isValid {
+					continue
+				}
+				if idx.redundancy != "duplicate" {
+					idx.redundancy, coveredBy = "duplicate", nil
+				}
+			case overlapping:
+				if idx.enforcesConstraint() || !other.isValid || idx.redundancy == "duplicate" {
+					continue
+				}
+				idx.redundancy = "overlapping"
+			default:
+				continue
+			}
+			if coveredBy == nil || other.outranks(*coveredBy) {
+				coveredBy = other
+			}
+		}
+		if coveredBy != nil {
+			idx.coveredBy = coveredBy.index.String
+		}
+	}
+}
+
+func (s indexStat) rank() int {
+	switch {
+	case s.isPrimary:
+		return 2
+	case s.isUnique || s.isExclusion:
+		return 1
+	}
+	return 0
+}
+
+// outranks defines which of two equivalent indexes should be kept.
+func (s indexStat) outranks(other indexStat) bool {
+	if s.rank() != other.rank() {
+		return s.rank() > other.rank()
+	}
+	return s.index.String < other.index.String
+}
+
+func (c *Collector) getIndexStats(ctx context.Context, topN int) ([]indexStat, error) {
+	var res []indexStat
+	err := c.forEachDatabase(ctx, func(name string, db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, `
+			SELECT s.schemaname, s.relname, s.indexrelname, s.idx_scan, s.idx_tup_read, s.idx_tup_fetch,
+				pg_relation_size(s.indexrelid), i.indisvalid, i.indisunique, i.indisprimary, i.indisexclusion,
+				am.amname, i.indkey::text, i.indclass::text, i.indcollation::text,
+				pg_get_expr(i.indpred, i.indrelid), pg_get_expr(i.indexprs, i.indrelid)
+			FROM pg_stat_user_indexes s
+			JOIN pg_index i ON i.indexrelid = s.indexrelid
+			JOIN pg_class c ON c.oid = s.indexrelid
+			JOIN pg_am am ON am.oid = c.relam`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		var indexes []indexStat
+		for rows.Next() {
+			s := indexStat{db: name}
+			var columns, opclasses, collations sql.NullString
+			err := rows.Scan(
+				&s.schema, &s.table, &s.index, &s.scans, &s.tupRead, &s.tupFetch,
+				&s.size, &s.isValid, &s.isUnique, &s.isPrimary, &s.isExclusion,
+				&s.method, &columns, &opclasses, &collations,
+				&s.predicate, &s.expressions,
+			)
+			if err != nil {
+				c.logger.Warning("failed to scan pg_stat_user_indexes row:", err)
+				continue
+			}
+			s.columns = strings.Fields(columns.String)
+			s.opclasses = strings.Fields(opclasses.String)
+			s.collations = strings.Fields(collations.String)
+			indexes = append(indexes, s)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		findRedundantIndexes(indexes)
+		res = append(res, indexes...)
+		return nil
+	})
+	return topIndexes(res, topN), err
+}
+
+// topIndexes returns the flagged (unused, invalid or redundant) indexes first, then the largest ones.
+func topIndexes(all []indexStat, n int) []indexStat {
+	sort.Slice(all, func(i, j int) bool {
+		if fi, fj := all[i].isFlagged(), all[j].isFlagged(); fi != fj {
+			return fi
+		}
+		return all[i].size.Int64 > all[j].size.Int64
+	})
+	if n > len(all) {
+		n = len(all)
+	}
+	return all[:n]
+}
+
+func (c *Collector) indexMetrics(ch chan<- prometheus.Metric) {
+	for _, s := range c.indexes {
+		db, schema, table, index := s.db, s.schema.String, s.table.String, s.index.String
+		counterIfValid(ch, dIndexScans, s.scans, db, schema, table, index)
+		counterIfValid(ch, dIndexRowsRead, s.tupRead, db, schema, table, index)
+		counterIfValid(ch, dIndexRowsFetched, s.tupFetch, db, schema, table, index)
+		gaugeIfValid(ch, dIndexSize, s.size, db, schema, table, index)
+		unused, invalid := 0., 0.
+		if s.isUnused() {
+			unused = 1
+		}
+		if !s.isValid {
+			invalid = 1
+		}
+		ch <- gauge(dIndexUnused, unused, db, schema, table, index)
+		ch <- gauge(dIndexInvalid, invalid, db, schema, table, index)
+		if s.redundancy != "" {
+			ch <- gauge(dIndexRedundant, 1, db, schema, table, index, s.redundancy, s.coveredBy)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package collector
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_findRedundantIndexes(t *testing.T) {
+	index := func(name string, columns ...string) indexStat {
+		opclasses := make([]string, len(columns))
+		for i := range opclasses {
+			opclasses[i] = "3124"
+		}
+		return indexStat{
+			db:        "db",
+			schema:    sql.NullString{String: "public", Valid: true},
+			table:     sql.NullString{String: "orders", Valid: true},
+			index:     sql.NullString{String: name, Valid: true},
+			isValid:   true,
+			method:    "btree",
+			columns:   columns,
+			opclasses: opclasses,
+		}
+	}
+	pkey := index("orders_pkey", "1")
+	pkey.isPrimary, pkey.isUnique = true, true
+	idxId := index("orders_id_idx", "1")
+	idxCustomer := index("orders_customer_idx", "2")
+	idxCustomerDate := index("orders_customer_date_idx", "2", "3")
+	idxCustomerDup := index("orders_customer_idx2", "2")
+	idxPartial := index("orders_customer_active_idx", "2")
+	idxPartial.predicate = sql.NullString{String: "(active = true)", Valid: true}
+	idxHash := index("orders_date_hash", "3")
+	idxHash.method = "hash"
+	idxDate := index("orders_date_status_idx", "3", "4")
+	uniqDate := index("orders_date_uniq", "3")
+	uniqDate.isUnique = true
+	uniqId := index("orders_id_key", "1")
+	uniqId.isUnique = true
+
+	indexes := []indexStat{pkey, idxId, idxCustomer, idxCustomerDate, idxCustomerDup, idxPartial, idxHash, idxDate, uniqDate, uniqId}
+	findRedundantIndexes(indexes)
+
+	redundancy := map[string][2]string{}
+	for _, i := range indexes {
+		if i.redundancy != "" {
+			redundancy[i.index.String] = [2]string{i.redundancy, i.coveredBy}
+		}
+	}
+	assert.Equal(t, map[string][2]string{
+		"orders_id_idx":        {"duplicate", "orders_pkey"},
+		"orders_id_key":        {"duplicate", "orders_pkey"},
+		"orders_customer_idx":  {"overlapping", "orders_customer_date_idx"},
+		"orders_customer_idx2": {"duplicate", "orders_customer_idx"},
+	}, redundancy)
+}
+
+func Test_indexStat_isUnused(t *testing.T) {
+	index := func(scans int64) indexStat {
+		return indexStat{scans: sql.NullInt64{Int64: scans, Valid: true}, isValid: true}
+	}
+	unused := index(0)
+	assert.True(t, unused.isUnused())
+
+	used := index(1)
+	assert.False(t, used.isUnused())
+
+	pkey := index(0)
+	pkey.isPrimary, pkey.isUnique = true, true
+	assert.False(t, pkey.isUnused())
+
+	unique := index(0)
+	unique.isUnique = true
+	assert.False(t, unique.isUnused())
+
+	exclusion := index(0)
+	exclusion.isExclusion = true
+	assert.False(t, exclusion.isUnused())
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type tableStat struct {
+	db     string
+	schema sql.NullString
+	table  sql.NullString
+
+	seqScan          sql.NullInt64
+	seqTupRead       sql.NullInt64
+	idxScan          sql.NullInt64
+	idxTupFetch      sql.NullInt64
+	tupInserted      sql.NullInt64
+	tupUpdated       sql.NullInt64
+	tupHotUpdated    sql.NullInt64
+	tupDeleted       sql.NullInt64
+	liveTup          sql.NullInt64
+	deadTup          sql.NullInt64
+	lastVacuum       sql.NullTime
+	lastAutovacuum   sql.NullTime
+	lastAnalyze      sql.NullTime
+	lastAutoanalyze  sql.NullTime
+	vacuumCount      sql.NullInt64
+	autovacuumCount  sql.NullInt64
+	analyzeCount     sql.NullInt64
+	autoanalyzeCount sql.NullInt64
+
+	heapBlksRead  sql.NullInt64
+	heapBlksHit   sql.NullInt64
+	idxBlksRead   sql.NullInt64
+	idxBlksHit    sql.NullInt64
+	toastBlksRead sql.NullInt64
+	toastBlksHit  sql.NullInt64
+	tidxBlksRead  sql.NullInt64
+	tidxBlksHit   sql.NullInt64
+}
+
+// blockAccesses is used to rank tables: the more blocks of a table and its indexes have been accessed, the busier it is.
+func (s tableStat) blockAccesses() int64 {
+	return s.heapBlksRead.Int64 + s.heapBlksHit.Int64 + s.idxBlksRead.Int64 + s.idxBlksHit.Int64 +
+		s.toastBlksRead.Int64 + s.toastBlksHit.Int64 + s.tidxBlksRead.Int64 + s.tidxBlksHit.Int64
+}
+
+func (c *Collector) getTableStats(ctx context.Context, topN int) ([]tableStat, error) {
+	var res []tableStat
+	err := c.forEachDatabase(ctx, func(name string, db *sql.DB) error {
+		rows, err := db.QueryContext(ctx, `
+			SELECT s.schemaname, s.relname,
+				s.seq_scan, s.seq_tup_read, s.idx_scan, s.idx_tup_fetch,
+				s.n_tup_ins, s.n_tup_upd, s.n_tup_hot_upd, s.n_tup_del, s.n_live_tup, s.n_dead_tup,
+				s.last_vacuum, s.last_autovacuum, s.last_analyze, s.last_autoanalyze,
+				s.vacuum_count, s.autovacuum_count, s.analyze_count, s.autoanalyze_count,
+				io.heap_blks_read, io.heap_blks_hit, io.idx_blks_read, io.idx_blks_hit,
+				io.toast_blks_read, io.toast_blks_hit, io.tidx_blks_read, io.tidx_blks_hit
+			FROM pg_stat_user_tables s JOIN pg_statio_user_tables io ON io.relid = s.relid`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			s := tableStat{db: name}
+			err := rows.Scan(
+				&s.schema, &s.table,
+				&s.seqScan, &s.seqTupRead, &s.idxScan, &s.idxTupFetch,
+				&s.tupInserted, &s.tupUpdated, &s.tupHotUpdated, &s.tupDeleted, &s.liveTup, &s.deadTup,
+				&s.lastVacuum, &s.lastAutovacuum, &s.lastAnalyze, &s.lastAutoanalyze,
+				&s.vacuumCount, &s.autovacuumCount, &s.analyzeCount, &s.autoanalyzeCount,
+				&s.heapBlksRead, &s.heapBlksHit, &s.idxBlksRead, &s.idxBlksHit,
+				&s.toastBlksRead, &s.toastBlksHit, &s.tidxBlksRead, &s.tidxBlksHit,
+			)
+			if err != nil {
+				c.logger.Warning("failed to scan pg_stat_user_tables row:", err)
+				continue
+			}
+			res = append(res, s)
+		}
+		return rows.Err()
+	})
+	return topTables(res, topN), err
+}
+
+func topTables(all []tableStat, n int) []tableStat {
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].blockAccesses() > all[j].blockAccesses()
+	})
+	if n > len(all) {
+		n = len(all)
+	}
+	return all[:n]
+}
+
+func (c *Collector) tableMetrics(ch chan<- prometheus.Metric) {
+	gaugeTime := func(desc *prometheus.Desc, v sql.NullTime, labels ...string) {
+		if v.Valid {
+			ch <- gauge(desc, float64(v.Time.Unix()), labels...)
+		}
+	}
+	for _, s := range c.tables {
+		db, schema, table := s.db, s.schema.String, s.table.String
+		counterIfValid(ch, dTableScans, s.seqScan, db, schema, table, "seq")
+		counterIfValid(ch, dTableScans, s.idxScan, db, schema, table, "index")
+		counterIfValid(ch, dTableRowsRead, s.seqTupRead, db, schema, table, "seq")
+		counterIfValid(ch, dTableRowsRead, s.idxTupFetch, db, schema, table, "index")
+		counterIfValid(ch, dTableRowsModified, s.tupInserted, db, schema, table, "inserted")
+		counterIfValid(ch, dTableRowsModified, s.tupUpdated, db, schema, table, "updated")
+		counterIfValid(ch, dTableRowsModified, s.tupHotUpdated, db, schema, table, "hot_updated")
+		counterIfValid(ch, dTableRowsModified, s.tupDeleted, db, schema, table, "deleted")
+		gaugeIfValid(ch, dTableRows, s.liveTup, db, schema, table, "live")
+		gaugeIfValid(ch, dTableRows, s.deadTup, db, schema, table, "dead")
+		gaugeTime(dTableLastVacuum, s.lastVacuum, db, schema, table, "manual")
+		gaugeTime(dTableLastVacuum, s.lastAutovacuum, db, schema, table, "auto")
+		gaugeTime(dTableLastAnalyze, s.lastAnalyze, db, schema, table, "manual")
+		gaugeTime(dTableLastAnalyze, s.lastAutoanalyze, db, schema, table, "auto")
+		counterIfValid(ch, dTableVacuums, s.vacuumCount, db, schema, table, "manual")
+		counterIfValid(ch, dTableVacuums, s.autovacuumCount, db, schema, table, "auto")
+		counterIfValid(ch, dTableAnalyzes, s.analyzeCount, db, schema, table, "manual")
+		counterIfValid(ch, dTableAnalyzes, s.autoanalyzeCount, db, schema, table, "auto")
+		counterIfValid(ch, dTableBlocksRead, s.heapBlksRead, db, schema, table, "heap")
+		counterIfValid(ch, dTableBlocksRead, s.idxBlksRead, db, schema, table, "index")
+		counterIfValid(ch, dTableBlocksRead, s.toastBlksRead, db, schema, table, "toast")
+		counterIfValid(ch, dTableBlocksRead, s.tidxBlksRead, db, schema, table, "toast_index")
+		counterIfValid(ch, dTableBlocksHit, s.heapBlksHit, db, schema, table, "heap")
+		counterIfValid(ch, dTableBlocksHit, s.idxBlksHit, db, schema, table, "index")
+		counterIfValid(ch, dTableBlocksHit, s.toastBlksHit, db, schema, table, "toast")
+		counterIfValid(ch, dTableBlocksHit, s.tidxBlksHit, db, schema, table, "toast_index")
+	}
+}
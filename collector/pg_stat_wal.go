@@ -0,0 +1,44 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type walStat struct {
+	records     sql.NullInt64
+	fpi         sql.NullInt64
+	bytes       sql.NullFloat64
+	buffersFull sql.NullInt64
+}
+
+func (c *Collector) getWalStat(ctx context.Context, version semver.Version) (*walStat, error) {
+	// the `pg_stat_wal` view has been introduced in 14
+	if semver.MustParseRange("<14.0.0")(version) {
+		return nil, nil
+	}
+	s := &walStat{}
+	err := c.db.QueryRowContext(ctx, `SELECT wal_records, wal_fpi, wal_bytes, wal_buffers_full FROM pg_stat_wal`).Scan(
+		&s.records, &s.fpi, &s.bytes, &s.buffersFull,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *Collector) walMetrics(ch chan<- prometheus.Metric) {
+	s := c.wal
+	if s == nil {
+		return
+	}
+	counterIfValid(ch, dWalRecords, s.records)
+	counterIfValid(ch, dWalFpi, s.fpi)
+	if s.bytes.Valid {
+		ch <- counter(dWalBytes, s.bytes.Float64)
+	}
+	counterIfValid(ch, dWalBuffersFull, s.buffersFull)
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/blang/semver"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxXidAge is the transaction ID age at which the whole cluster would wrap around.
+// Postgres refuses to start new transactions a few million transactions earlier.
+const maxXidAge = 1<<31 - 1
+
+type wraparoundStat struct {
+	db                     sql.NullString
+	xidAge                 sql.NullInt64
+	mxidAge                sql.NullInt64
+	oldestRelation         sql.NullString
+	oldestRelationXidAge   sql.NullInt64
+	oldestRelationMxidAge  sql.NullInt64
+	oldestRelationResolved bool
+
+	freezeMaxAge     sql.NullInt64
+	mxidFreezeMaxAge sql.NullInt64
+}
+
+func (c *Collector) getWraparound(ctx context.Context, version semver.Version) ([]wraparoundStat, error) {
+	// mxid_age() has been introduced in 9.5
+	dbMxidAge, relMxidAge, mxidFreezeMaxAge := "null::bigint", "null::bigint", "null::bigint"
+	if semver.MustParseRange(">=9.5.0")(version) {
+		dbMxidAge, relMxidAge = "mxid_age(datminmxid)", "mxid_age(c.relminmxid)"
+		mxidFreezeMaxAge = "current_setting('autovacuum_multixact_freeze_max_age')::bigint"
+	}
+	// the settings are read here rather than taken from the `settings` collector, which may be disabled
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT datname, age(datfrozenxid), `+dbMxidAge+`,
+			current_setting('autovacuum_freeze_max_age')::bigint, `+mxidFreezeMaxAge+`
+		FROM pg_database`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []wraparoundStat
+	byDB := map[string]int{}
+	for rows.Next() {
+		var s wraparoundStat
+		if err := rows.Scan(&s.db, &s.xidAge, &s.mxidAge, &s.freezeMaxAge, &s.mxidFreezeMaxAge); err != nil {
+			c.logger.Warning("failed to scan pg_database row:", err)
+			continue
+		}
+		byDB[s.db.String] = len(res)
+		res = append(res, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = c.forEachDatabase(ctx, func(name string, db *sql.DB) error {
+		i, ok := byDB[name]
+		if !ok {
+			return nil
+		}
+		s := &res[i]
+		err := db.QueryRowContext(ctx, `
+			SELECT c.oid::regclass::text, age(c.relfrozenxid), `+relMxidAge+`
+			FROM pg_class c
+			WHERE c.relkind IN ('r', 'm', 't')
+			ORDER BY age(c.relfrozenxid) DESC LIMIT 1`).Scan(&s.oldestRelation, &s.oldestRelationXidAge, &s.oldestRelationMxidAge)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		s.oldestRelationResolved = err == nil
+		return nil
+	})
+	return res, err
+}
+
+func (c *Collector) wraparoundMetrics(ch chan<- prometheus.Metric) {
+	for _, s := range c.wraparound {
+		db := s.db.String
+		gaugeIfValid(ch, dDbXidAge, s.xidAge, db)
+		gaugeIfValid(ch, dDbMxidAge, s.mxidAge, db)
+		if s.xidAge.Valid {
+			ch <- gauge(dDbXidWraparoundRemaining, float64(maxXidAge-s.xidAge.Int64), db)
+			if s.freezeMaxAge.Valid {
+				ch <- gauge(dDbXidFreezeRemaining, float64(s.freezeMaxAge.Int64-s.xidAge.Int64), db)
+			}
+		}
+		if s.mxidAge.Valid && s.mxidFreezeMaxAge.Valid {
+			ch <- gauge(dDbMxidFreezeRemaining, float64(s.mxidFreezeMaxAge.Int64-s.mxidAge.Int64), db)
+		}
+		if s.oldestRelationResolved {
+			gaugeIfValid(ch, dDbOldestRelationXidAge, s.oldestRelationXidAge, db, s.oldestRelation.String)
+			gaugeIfValid(ch, dDbOldestRelationMxidAge, s.oldestRelationMxidAge, db, s.oldestRelation.String)
+		}
+	}
+}
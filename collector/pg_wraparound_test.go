@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWraparoundMetrics(t *testing.T) {
+	c := &Collector{
+		wraparound: []wraparoundStat{
+			{
+				db: nullString("db1"), xidAge: nullInt64(150000000), mxidAge: nullInt64(1000),
+				oldestRelation: nullString("public.orders"), oldestRelationXidAge: nullInt64(150000000), oldestRelationMxidAge: nullInt64(1000), oldestRelationResolved: true,
+				freezeMaxAge: nullInt64(200000000), mxidFreezeMaxAge: nullInt64(400000000),
+			},
+			{
+				// before 9.5 there are no multixact ages, the relation of an unreachable database is unknown
+				db: nullString("db2"), xidAge: nullInt64(1000),
+				freezeMaxAge: nullInt64(200000000),
+			},
+		},
+	}
+
+	assert.NoError(t, testutil.CollectAndCompare(collectFunc(c.wraparoundMetrics), strings.NewReader(`
+# HELP pg_db_xid_age Age of the oldest unfrozen transaction ID in the database (age(datfrozenxid))
+# TYPE pg_db_xid_age gauge
+pg_db_xid_age{db="db1"} 1.5e+08
+pg_db_xid_age{db="db2"} 1000
+# HELP pg_db_mxid_age Age of the oldest unfrozen multixact ID in the database (mxid_age(datminmxid))
+# TYPE pg_db_mxid_age gauge
+pg_db_mxid_age{db="db1"} 1000
+# HELP pg_db_xid_autovacuum_freeze_remaining Number of transactions left until autovacuum_freeze_max_age forces an anti-wraparound vacuum in the database
+# TYPE pg_db_xid_autovacuum_freeze_remaining gauge
+pg_db_xid_autovacuum_freeze_remaining{db="db1"} 5e+07
+pg_db_xid_autovacuum_freeze_remaining{db="db2"} 1.99999e+08
+# HELP pg_db_mxid_autovacuum_freeze_remaining Number of multixacts left until autovacuum_multixact_freeze_max_age forces an anti-wraparound vacuum in the database
+# TYPE pg_db_mxid_autovacuum_freeze_remaining gauge
+pg_db_mxid_autovacuum_freeze_remaining{db="db1"} 3.99999e+08
+# HELP pg_db_xid_wraparound_remaining Number of transactions left until the transaction ID wraparound
+# TYPE pg_db_xid_wraparound_remaining gauge
+pg_db_xid_wraparound_remaining{db="db1"} 1.997483647e+09
+pg_db_xid_wraparound_remaining{db="db2"} 2.147482647e+09
+# HELP pg_db_oldest_relation_xid_age Age of the relation with the oldest unfrozen transaction ID (age(relfrozenxid)) in the database
+# TYPE pg_db_oldest_relation_xid_age gauge
+pg_db_oldest_relation_xid_age{db="db1",relation="public.orders"} 1.5e+08
+# HELP pg_db_oldest_relation_mxid_age Age of the oldest unfrozen multixact ID (mxid_age(relminmxid)) of the relation with the oldest unfrozen transaction ID
+# TYPE pg_db_oldest_relation_mxid_age gauge
+pg_db_oldest_relation_mxid_age{db="db1",relation="public.orders"} 1000
+`)))
+}
@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	xminHolderBackend            = "backend"
+	xminHolderPreparedXact       = "prepared_xact"
+	xminHolderReplicationSlot    = "replication_slot"
+	xminHolderReplicationCatalog = "replication_slot_catalog"
+	xminHolderStandby            = "standby"
+)
+
+// xminHolder is something that prevents VACUUM from removing dead rows: a transaction, a replication slot or a standby
+// with hot_standby_feedback enabled.
+type xminHolder struct {
+	kind string
+	db   string
+	user string
+	name string
+	age  int64
+}
+
+func (c *Collector) getXminHolders(ctx context.Context) ([]xminHolder, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT 'prepared_xact', database::text, owner::text, gid, age(transaction) FROM pg_prepared_xacts
+		UNION ALL
+		SELECT 'replication_slot', coalesce(database::text, ''), '', slot_name::text, age(xmin) FROM pg_replication_slots WHERE xmin IS NOT NULL
+		UNION ALL
+		SELECT 'replication_slot_catalog', coalesce(database::text, ''), '', slot_name::text, age(catalog_xmin) FROM pg_replication_slots WHERE catalog_xmin IS NOT NULL
+		UNION ALL
+		SELECT 'standby', '', coalesce(usename::text, ''), coalesce(application_name, ''), age(backend_xmin) FROM pg_stat_replication WHERE backend_xmin IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []xminHolder
+	for rows.Next() {
+		var (
+			h                    xminHolder
+			kind, db, user, name sql.NullString
+			age                  sql.NullInt64
+		)
+		if err := rows.Scan(&kind, &db, &user, &name, &age); err != nil {
+			c.logger.Warning("failed to scan xmin holder row:", err)
+			continue
+		}
+		if !age.Valid {
+			continue
+		}
+		h.kind, h.db, h.user, h.name, h.age = kind.String, db.String, user.String, name.String, age.Int64
+		res = append(res, h)
+	}
+	return res, nil
+}
+
+func (c *Collector) xminHorizonMetrics(ch chan<- prometheus.Metric) {
+	holders := c.xminHolders
+	if c.saCurr != nil {
+		for _, conn := range c.saCurr.connections {
+			age := conn.XminAge
+			if conn.XidAge.Int64 > age.Int64 {
+				age = conn.XidAge
+			}
+			if !age.Valid {
+				continue
+			}
+			k := conn.QueryKey()
+			holders = append(holders, xminHolder{kind: xminHolderBackend, db: k.DB, user: k.User, name: k.Query, age: age.Int64})
+		}
+	}
+	oldest := map[string]xminHolder{}
+	for _, h := range holders {
+		if o, ok := oldest[h.kind]; !ok || h.age > o.age {
+			oldest[h.kind] = h
+		}
+	}
+	for _, kind := range []string{xminHolderBackend, xminHolderPreparedXact, xminHolderReplicationSlot, xminHolderReplicationCatalog, xminHolderStandby} {
+		if h, ok := oldest[kind]; ok {
+			ch <- gauge(dXminHorizonAge, float64(h.age), kind, h.db, h.user, h.name)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package collector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXminHorizonMetrics(t *testing.T) {
+	c := &Collector{
+		xminHolders: []xminHolder{
+			{kind: xminHolderPreparedXact, db: "db", user: "app", name: "tx1", age: 100},
+			{kind: xminHolderPreparedXact, db: "db", user: "app", name: "tx2", age: 300},
+		},
+		saCurr: &saSnapshot{connections: map[int]Connection{
+			1: {DB: nullString("db"), User: nullString("app"), Query: nullString("SELECT 1"), XminAge: nullInt64(50), XidAge: nullInt64(70)},
+			2: {DB: nullString("db"), User: nullString("app"), Query: nullString("SELECT 1"), XminAge: nullInt64(20)},
+			3: {DB: nullString("db"), User: nullString("app")},
+		}},
+	}
+
+	// the kinds without holders, e.g., replication slots, are absent rather than zero
+	assert.NoError(t, testutil.CollectAndCompare(collectFunc(c.xminHorizonMetrics), strings.NewReader(`
+# HELP pg_xmin_horizon_age Age of the oldest xmin held back by each kind of holder: backends, prepared transactions, replication slots and standbys with hot_standby_feedback
+# TYPE pg_xmin_horizon_age gauge
+pg_xmin_horizon_age{db="db",holder="backend",name="select ?",user="app"} 70
+pg_xmin_horizon_age{db="db",holder="prepared_xact",name="tx2",user="app"} 300
+`)))
+}
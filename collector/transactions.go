@@ -0,0 +1,80 @@
+package collector
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const xactDurationsTTL = time.Hour
+
+var xactDurationBuckets = []float64{0.1, 1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+type xactHistogram struct {
+	count      uint64
+	sum        float64
+	buckets    map[float64]uint64
+	lastUpdate time.Time
+}
+
+// xactDurations accumulates the durations of the transactions finished between two pg_stat_activity snapshots.
+// A duration is measured up to the last snapshot the transaction was seen in, so it's rounded down by less than the scrape interval.
+// Transactions that start and finish between the snapshots are never seen, so they aren't counted.
+type xactDurations struct {
+	histograms map[QueryKey]*xactHistogram
+}
+
+func newXactDurations() *xactDurations {
+	return &xactDurations{histograms: map[QueryKey]*xactHistogram{}}
+}
+
+func (d *xactDurations) update(prev, curr *saSnapshot) {
+	if prev == nil || curr == nil || prev == curr {
+		return
+	}
+	for pid, p := range prev.connections {
+		if !p.XactStart.Valid {
+			continue
+		}
+		// the same backend (not a reused pid) in the same transaction
+		if c, ok := curr.connections[pid]; ok && sameTime(c.BackendStart, p.BackendStart) && sameTime(c.XactStart, p.XactStart) {
+			continue
+		}
+		d.observe(p.QueryKey(), prev.ts.Sub(p.XactStart.Time).Seconds(), curr.ts)
+	}
+	for k, h := range d.histograms {
+		if curr.ts.Sub(h.lastUpdate) > xactDurationsTTL {
+			delete(d.histograms, k)
+		}
+	}
+}
+
+func sameTime(a, b sql.NullTime) bool {
+	return a.Valid == b.Valid && a.Time.Equal(b.Time)
+}
+
+func (d *xactDurations) observe(k QueryKey, duration float64, now time.Time) {
+	h := d.histograms[k]
+	if h == nil {
+		h = &xactHistogram{buckets: map[float64]uint64{}}
+		for _, b := range xactDurationBuckets {
+			h.buckets[b] = 0
+		}
+		d.histograms[k] = h
+	}
+	h.count++
+	h.sum += duration
+	for _, b := range xactDurationBuckets {
+		if duration <= b {
+			h.buckets[b]++
+		}
+	}
+	h.lastUpdate = now
+}
+
+func (d *xactDurations) metrics(ch chan<- prometheus.Metric) {
+	for k, h := range d.histograms {
+		ch <- prometheus.MustNewConstHistogram(dTransactionDuration, h.count, h.sum, h.buckets, k.DB, k.User, k.Query)
+	}
+}
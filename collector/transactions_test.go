@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionMetrics(t *testing.T) {
+	ts := time.Now()
+	ago := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: ts.Add(-d), Valid: true} }
+	c := &Collector{
+		saCurr: &saSnapshot{
+			ts: ts,
+			connections: map[int]Connection{
+				1: {
+					DB: nullString("db"), User: nullString("app"), State: nullString("idle in transaction"), Query: nullString("SELECT 1"),
+					BackendStart: ago(time.Hour), XactStart: ago(2 * time.Minute), StateChange: ago(time.Minute),
+				},
+				2: {
+					DB: nullString("db"), User: nullString("app"), State: nullString("active"), Query: nullString("SELECT 1"),
+					BackendStart: ago(time.Minute), XactStart: ago(5 * time.Second), StateChange: ago(5 * time.Second),
+				},
+				3: {
+					DB: nullString("db"), User: nullString("app"), State: nullString("idle"),
+					BackendStart: ago(10 * time.Minute), StateChange: ago(30 * time.Second),
+				},
+			},
+		},
+		xactDurations: newXactDurations(),
+	}
+
+	assert.NoError(t, testutil.CollectAndCompare(collectFunc(c.transactionMetrics), strings.NewReader(`
+# HELP pg_connections_max_xact_age_seconds The longest time a transaction of the connections has been open
+# TYPE pg_connections_max_xact_age_seconds gauge
+pg_connections_max_xact_age_seconds{db="db",query="select ?",user="app"} 120
+# HELP pg_connections_max_state_duration_seconds The longest time the connections have been in the current state, e.g., idle in transaction
+# TYPE pg_connections_max_state_duration_seconds gauge
+pg_connections_max_state_duration_seconds{db="db",query="select ?",state="idle in transaction",user="app"} 60
+pg_connections_max_state_duration_seconds{db="db",query="select ?",state="active",user="app"} 5
+pg_connections_max_state_duration_seconds{db="db",query="",state="idle",user="app"} 30
+`)))
+}
+
+func TestTransactionDurations(t *testing.T) {
+	ts := time.Now()
+	at := func(d time.Duration) sql.NullTime { return sql.NullTime{Time: ts.Add(d), Valid: true} }
+	prev := &saSnapshot{
+		ts: ts,
+		connections: map[int]Connection{
+			// still open
+			1: {DB: nullString("db"), User: nullString("app"), Query: nullString("SELECT 1"), BackendStart: at(-time.Hour), XactStart: at(-2 * time.Minute)},
+			// finished, the backend is gone
+			2: {DB: nullString("db"), User: nullString("app"), Query: nullString("SELECT 1"), BackendStart: at(-time.Hour), XactStart: at(-5 * time.Second)},
+			// finished, the pid is reused by another backend in a transaction started at the same time
+			3: {DB: nullString("db"), User: nullString("app"), Query: nullString("SELECT 1"), BackendStart: at(-time.Hour), XactStart: at(-30 * time.Second)},
+			// finished, the backend has started another transaction
+			4: {DB: nullString("db"), User: nullString("app"), Query: nullString("COMMIT"), BackendStart: at(-time.Hour), XactStart: at(-50 * time.Millisecond)},
+			// not in a transaction
+			5: {DB: nullString("db"), User: nullString("app"), BackendStart: at(-time.Hour)},
+		},
+	}
+	curr := &saSnapshot{
+		ts: ts.Add(15 * time.Second),
+		connections: map[int]Connection{
+			1: prev.connections[1],
+			3: {DB: nullString("db"), User: nullString("app"), Query: nullString("SELECT 1"), BackendStart: at(time.Second), XactStart: at(-30 * time.Second)},
+			4: {DB: nullString("db"), User: nullString("app"), Query: nullString("COMMIT"), BackendStart: at(-time.Hour), XactStart: at(time.Second)},
+			5: prev.connections[5],
+		},
+	}
+
+	d := newXactDurations()
+	d.update(prev, curr)
+	d.update(curr, curr)
+
+	assert.NoError(t, testutil.CollectAndCompare(collectFunc(d.metrics), strings.NewReader(`
+# HELP pg_transaction_duration_seconds Durations of the finished transactions
+# TYPE pg_transaction_duration_seconds histogram
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="0.1"} 0
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="1"} 0
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="5"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="10"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="30"} 2
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="60"} 2
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="300"} 2
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="600"} 2
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="1800"} 2
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="3600"} 2
+pg_transaction_duration_seconds_bucket{db="db",query="select ?",user="app",le="+Inf"} 2
+pg_transaction_duration_seconds_sum{db="db",query="select ?",user="app"} 35
+pg_transaction_duration_seconds_count{db="db",query="select ?",user="app"} 2
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="0.1"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="1"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="5"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="10"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="30"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="60"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="300"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="600"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="1800"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="3600"} 1
+pg_transaction_duration_seconds_bucket{db="db",query="commit",user="app",le="+Inf"} 1
+pg_transaction_duration_seconds_sum{db="db",query="commit",user="app"} 0.05
+pg_transaction_duration_seconds_count{db="db",query="commit",user="app"} 1
+`)))
+}
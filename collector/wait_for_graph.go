@@ -0,0 +1,108 @@
+package collector
+
+import (
+	"sort"
+)
+
+// waitForGraph is built from `pg_blocking_pids()` of every session.
+// A session can wait for several others (e.g., a lock requested in a mode conflicting with multiple holders)
+// and the blockers can be waiting themselves, so the graph is a forest of blocking chains rather than a flat list.
+type waitForGraph struct {
+	blockedBy map[int][]int
+	blocking  map[int][]int
+}
+
+type blockingChain struct {
+	rootPid int
+	depth   int
+	blocked int
+}
+
+func newWaitForGraph(connections map[int]Connection) *waitForGraph {
+	g := &waitForGraph{blockedBy: map[int][]int{}, blocking: map[int][]int{}}
+	for pid, conn := range connections {
+		seen := map[int]bool{}
+		for _, b := range conn.BlockingPids {
+			blocker := int(b)
+			if blocker <= 0 || blocker == pid || seen[blocker] { // parallel workers may produce duplicates
+				continue
+			}
+			seen[blocker] = true
+			g.blockedBy[pid] = append(g.blockedBy[pid], blocker)
+			g.blocking[blocker] = append(g.blocking[blocker], pid)
+		}
+	}
+	for _, pids := range g.blocking {
+		sort.Ints(pids)
+	}
+	for _, pids := range g.blockedBy {
+		sort.Ints(pids)
+	}
+	return g
+}
+
+// roots returns the sessions that block others while not waiting for anyone.
+// Sessions involved in a deadlock have no root and are resolved by Postgres itself.
+func (g *waitForGraph) roots() []int {
+	var res []int
+	for pid := range g.blocking {
+		if len(g.blockedBy[pid]) == 0 {
+			res = append(res, pid)
+		}
+	}
+	sort.Ints(res)
+	return res
+}
+
+func (g *waitForGraph) waiters(pid int) []int {
+	return g.blocking[pid]
+}
+
+// chains returns the depth and the number of transitively blocked sessions for every root blocker.
+// The depth is the longest path from the root, a session waiting for several chains is counted in each of them.
+func (g *waitForGraph) chains() []blockingChain {
+	var res []blockingChain
+	depths := map[int]int{}
+	for _, root := range g.roots() {
+		chain := blockingChain{rootPid: root, depth: g.depth(root, depths, map[int]bool{})}
+		visited := map[int]bool{root: true}
+		level := []int{root}
+		for len(level) > 0 {
+			var next []int
+			for _, pid := range level {
+				for _, w := range g.blocking[pid] {
+					if visited[w] {
+						continue
+					}
+					visited[w] = true
+					next = append(next, w)
+				}
+			}
+			chain.blocked += len(next)
+			level = next
+		}
+		res = append(res, chain)
+	}
+	return res
+}
+
+// depth returns the length of the longest path of waiting sessions starting from pid.
+// Sessions of a deadlock below the root are not followed twice.
+func (g *waitForGraph) depth(pid int, depths map[int]int, path map[int]bool) int {
+	if d, ok := depths[pid]; ok {
+		return d
+	}
+	path[pid] = true
+	d := 0
+	for _, w := range g.blocking[pid] {
+		if path[w] {
+			continue
+		}
+		if wd := g.depth(w, depths, path) + 1; wd > d {
+			d = wd
+		}
+	}
+	delete(path, pid)
+	depths[pid] = d
+	return d
+}
@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitForGraph(t *testing.T) {
+	conn := func(blockingPids ...int64) Connection {
+		return Connection{BlockingPids: blockingPids}
+	}
+	connections := map[int]Connection{
+		1: conn(),        // idle in transaction
+		2: conn(1),       // waits for 1
+		3: conn(2),       // waits for 2
+		4: conn(2, 1, 1), // waits for both
+		5: conn(),
+		6: conn(7), // the blocker is not in the snapshot
+		8: conn(9), // deadlock
+		9: conn(8),
+
+		10: conn(),       // the root of a chain with a shortcut
+		11: conn(10),     // waits for 10
+		12: conn(11),     // waits for 11
+		13: conn(12, 10), // waits for 12 and directly for 10
+		20: conn(),       // the root of a deadlock
+		21: conn(20, 22), // waits for 20 and 22
+		22: conn(21),     // waits for 21
+	}
+	g := newWaitForGraph(connections)
+
+	assert.Equal(t, []int{1, 7, 10, 20}, g.roots())
+	assert.Equal(t, []int{2, 4}, g.waiters(1))
+	assert.Equal(t, []int{3, 4}, g.waiters(2))
+	assert.Nil(t, g.waiters(5))
+
+	assert.Equal(t,
+		[]blockingChain{
+			{rootPid: 1, depth: 2, blocked: 3},
+			{rootPid: 7, depth: 1, blocked: 1},
+			{rootPid: 10, depth: 3, blocked: 3},
+			{rootPid: 20, depth: 2, blocked: 2},
+		},
+		g.chains(),
+	)
+}
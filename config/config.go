@@ -0,0 +1,129 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coroot/coroot-pg-agent/collector"
+	"gopkg.in/yaml.v3"
+)
+
+const InstanceLabel = "instance"
+
+type Config struct {
+	Global  Global   `yaml:"global"`
+	Targets []Target `yaml:"targets"`
+}
+
+type Global struct {
+	ScrapeInterval time.Duration                         `yaml:"scrape_interval"`
+	CollectTimeout time.Duration                         `yaml:"collect_timeout"`
+	Labels         map[string]string                     `yaml:"labels"`
+	Collectors     map[string]collector.CollectorOptions `yaml:"collectors"`
+}
+
+type Target struct {
+	Name           string                                `yaml:"name"`
+	DSN            string                                `yaml:"dsn"`
+	Labels         map[string]string                     `yaml:"labels"`
+	ScrapeInterval time.Duration                         `yaml:"scrape_interval"`
+	CollectTimeout time.Duration                         `yaml:"collect_timeout"`
+	Collectors     map[string]collector.CollectorOptions `yaml:"collectors"`
+}
+
+// Load reads the config file. Settings missing from its global section are taken from defaults.
+func Load(path string, defaults Global) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg := &Config{}
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	cfg.Global = defaults.merge(cfg.Global)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) Validate() error {
+	if len(cfg.Targets) == 0 {
+		return fmt.Errorf("no targets defined")
+	}
+	if _, ok := cfg.Global.Labels[InstanceLabel]; ok {
+		return fmt.Errorf("the %s label is reserved", InstanceLabel)
+	}
+	names := map[string]bool{}
+	for i, t := range cfg.Targets {
+		if t.Name == "" && len(cfg.Targets) > 1 {
+			return fmt.Errorf("target #%d: name is required", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("target %s: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+		if t.DSN == "" {
+			return fmt.Errorf("target %s: dsn is required", t.Name)
+		}
+		if _, ok := t.Labels[InstanceLabel]; ok {
+			return fmt.Errorf("target %s: the %s label is reserved", t.Name, InstanceLabel)
+		}
+		options := cfg.Options(t)
+		if options.ScrapeInterval <= 0 || options.CollectTimeout <= 0 {
+			return fmt.Errorf("target %s: scrape_interval and collect_timeout must be positive", t.Name)
+		}
+		if err := options.Validate(); err != nil {
+			return fmt.Errorf("target %s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// Options returns the collector options of the target with the global settings applied.
+func (cfg *Config) Options(t Target) collector.Options {
+	g := cfg.Global.merge(Global{ScrapeInterval: t.ScrapeInterval, CollectTimeout: t.CollectTimeout, Collectors: t.Collectors})
+	return collector.Options{
+		ScrapeInterval: g.ScrapeInterval,
+		CollectTimeout: g.CollectTimeout,
+		Collectors:     g.Collectors,
+	}
+}
+
+// Labels returns the static labels of the target including the global ones.
+func (cfg *Config) Labels(t Target) map[string]string {
+	return cfg.Global.merge(Global{Labels: t.Labels}).Labels
+}
+
+func (g Global) merge(other Global) Global {
+	res := Global{
+		ScrapeInterval: g.ScrapeInterval,
+		CollectTimeout: g.CollectTimeout,
+		Labels:         map[string]string{},
+		Collectors:     map[string]collector.CollectorOptions{},
+	}
+	if other.ScrapeInterval != 0 {
+		res.ScrapeInterval = other.ScrapeInterval
+	}
+	if other.CollectTimeout != 0 {
+		res.CollectTimeout = other.CollectTimeout
+	}
+	for k, v := range g.Labels {
+		res.Labels[k] = v
+	}
+	for k, v := range other.Labels {
+		res.Labels[k] = v
+	}
+	for name, o := range g.Collectors {
+		res.Collectors[name] = o
+	}
+	for name, o := range other.Collectors {
+		res.Collectors[name] = res.Collectors[name].Merge(o)
+	}
+	return res
+}
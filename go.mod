@@ -8,6 +8,8 @@ require (
 	github.com/dustin/go-humanize v1.0.0
 	github.com/lib/pq v1.10.3
 	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/client_model v0.2.0
 	github.com/stretchr/testify v1.6.1
 	gopkg.in/alecthomas/kingpin.v2 v2.2.6
+	gopkg.in/yaml.v3 v3.0.1
 )
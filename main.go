@@ -1,10 +1,15 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/coroot/coroot-pg-agent/collector"
+	"github.com/coroot/coroot-pg-agent/config"
 	"github.com/coroot/logger"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -14,7 +19,8 @@ import (
 var version = "unknown"
 
 func main() {
-	dsn := kingpin.Arg("dsn", `Data source name (env: DSN) - "postgresql://<user>:<password>@<host>:5432/postgres?connect_timeout=1&statement_timeout=30000".`).Envar("DSN").Required().String()
+	dsn := kingpin.Arg("dsn", `Data source name (env: DSN) - "postgresql://<user>:<password>@<host>:5432/postgres?connect_timeout=1&statement_timeout=30000".`).Envar("DSN").String()
+	configFile := kingpin.Flag("config.file", `Path to a YAML file listing the Postgres servers to monitor, reloaded on SIGHUP or POST /-/reload (env: CONFIG_FILE)`).Envar("CONFIG_FILE").String()
 	listen := kingpin.Flag("listen", `Listen address (env: LISTEN) - "<ip>:<port>" or ":<port>".`).Envar("LISTEN").Default("0.0.0.0:80").String()
 	scrapeInterval := kingpin.Flag("scrape-interval", `How often to snapshot system views (env: PG_SCRAPE_INTERVAL)`).Envar("PG_SCRAPE_INTERVAL").Default("15s").Duration()
 	collectTimeout := kingpin.Flag("collect-timeout", `Timeout for the entire collect operation`).Envar("PG_COLLECT_TIMEOUT").Default("5s").Duration()
@@ -26,20 +32,92 @@ func main() {
 
 	log := logger.NewKlog("")
 
-	c, err := collector.New(*dsn, *scrapeInterval, *collectTimeout, log)
+	defaults := config.Global{ScrapeInterval: *scrapeInterval, CollectTimeout: *collectTimeout, Labels: *staticLabels}
+	loadConfig := func() (*config.Config, error) {
+		if *configFile != "" {
+			return config.Load(*configFile, defaults)
+		}
+		cfg := &config.Config{Global: defaults, Targets: []config.Target{{DSN: *dsn}}}
+		return cfg, cfg.Validate()
+	}
+	if *configFile == "" && *dsn == "" {
+		log.Error("either a DSN or --config.file must be specified")
+		return
+	}
+
+	targets := newTargets(log)
+	cfg, err := loadConfig()
 	if err != nil {
 		log.Error(err)
 		return
 	}
+	log.Info("static labels:", cfg.Global.Labels)
+	if err = targets.apply(cfg); err != nil {
+		log.Error(err)
+		return
+	}
 
-	registry := prometheus.NewRegistry()
+	reload := func() error {
+		if *configFile == "" {
+			return fmt.Errorf("no config file to reload")
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if err = targets.apply(cfg); err != nil {
+			return err
+		}
+		log.Info("config reloaded")
+		return nil
+	}
 
-	log.Info("static labels:", *staticLabels)
-	registerer := prometheus.WrapRegistererWith(*staticLabels, registry)
-	registerer.MustRegister(info("pg_agent_info", version))
-	registerer.MustRegister(c)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reload(); err != nil {
+				log.Error("failed to reload config:", err)
+			}
+		}
+	}()
 
-	http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	http.Handle("/metrics", promhttp.HandlerFor(prometheus.GathererFunc(targets.gather), promhttp.HandlerOpts{}))
+	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("target")
+		if name == "" {
+			http.Error(w, "the target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		probeRegistry := targets.probe(name)
+		if probeRegistry == nil {
+			http.Error(w, fmt.Sprintf("unknown target %q", name), http.StatusNotFound)
+			return
+		}
+		promhttp.HandlerFor(probeRegistry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+	http.HandleFunc("/api/locks", func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("target")
+		c := targets.collector(name)
+		if c == nil {
+			http.Error(w, fmt.Sprintf("unknown target %q", name), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(c.LockTree()); err != nil {
+			log.Warning("failed to encode lock tree:", err)
+		}
+	})
+	http.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			http.Error(w, "only POST or PUT requests are allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := reload(); err != nil {
+			log.Error("failed to reload config:", err)
+			http.Error(w, fmt.Sprintf("failed to reload config: %s", err), http.StatusInternalServerError)
+		}
+	})
 	log.Info("listening on:", *listen)
 	log.Error(http.ListenAndServe(*listen, nil))
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/coroot/coroot-pg-agent/collector"
+	"github.com/coroot/coroot-pg-agent/config"
+	"github.com/coroot/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type target struct {
+	dsn     string
+	options collector.Options
+	labels  map[string]string
+
+	collector       *collector.Collector
+	metricsRegistry *prometheus.Registry
+	probeRegistry   *prometheus.Registry
+}
+
+func (t *target) register(name string) error {
+	t.metricsRegistry = prometheus.NewRegistry()
+	t.probeRegistry = prometheus.NewRegistry()
+	if err := prometheus.WrapRegistererWith(t.labels, t.probeRegistry).Register(t.collector); err != nil {
+		return err
+	}
+	labels := prometheus.Labels{}
+	for k, v := range t.labels {
+		labels[k] = v
+	}
+	if name != "" {
+		labels[config.InstanceLabel] = name
+	}
+	return prometheus.WrapRegistererWith(labels, t.metricsRegistry).Register(t.collector)
+}
+
+// targets keeps a long-lived collector per configured Postgres server,
+// so the delta state of a collector survives config reloads that don't affect it.
+type targets struct {
+	applyLock sync.Mutex
+
+	lock         sync.RWMutex
+	infoRegistry *prometheus.Registry
+	byName       map[string]*target
+
+	logger logger.Logger
+}
+
+func newTargets(logger logger.Logger) *targets {
+	return &targets{byName: map[string]*target{}, logger: logger}
+}
+
+func (ts *targets) apply(cfg *config.Config) error {
+	ts.applyLock.Lock()
+	defer ts.applyLock.Unlock()
+
+	infoRegistry := prometheus.NewRegistry()
+	if err := prometheus.WrapRegistererWith(cfg.Global.Labels, infoRegistry).Register(info("pg_agent_info", version)); err != nil {
+		return err
+	}
+
+	ts.lock.RLock()
+	current := ts.byName
+	ts.lock.RUnlock()
+
+	byName := map[string]*target{}
+	var created []*collector.Collector
+	for _, tc := range cfg.Targets {
+		t := &target{dsn: tc.DSN, options: cfg.Options(tc), labels: cfg.Labels(tc)}
+		if prev := current[tc.Name]; prev != nil && prev.dsn == t.dsn && reflect.DeepEqual(prev.options, t.options) {
+			t.collector = prev.collector
+		} else {
+			c, err := collector.New(t.dsn, t.options, logger.NewKlog(tc.Name))
+			if err != nil {
+				closeAll(created)
+				return fmt.Errorf("target %s: %w", tc.Name, err)
+			}
+			created = append(created, c)
+			t.collector = c
+			if prev != nil {
+				ts.logger.Info("reconfiguring target:", tc.Name)
+			} else {
+				ts.logger.Info("adding target:", tc.Name)
+			}
+		}
+		if err := t.register(tc.Name); err != nil {
+			closeAll(created)
+			return fmt.Errorf("target %s: %w", tc.Name, err)
+		}
+		byName[tc.Name] = t
+	}
+
+	ts.lock.Lock()
+	ts.infoRegistry = infoRegistry
+	ts.byName = byName
+	ts.lock.Unlock()
+
+	for name, prev := range current {
+		if t := byName[name]; t != nil && t.collector == prev.collector {
+			continue
+		}
+		if byName[name] == nil {
+			ts.logger.Info("removing target:", name)
+		}
+		if err := prev.collector.Close(); err != nil {
+			ts.logger.Warning(err)
+		}
+	}
+	return nil
+}
+
+func (ts *targets) gather() ([]*dto.MetricFamily, error) {
+	ts.lock.RLock()
+	gatherers := prometheus.Gatherers{ts.infoRegistry}
+	for _, t := range ts.byName {
+		gatherers = append(gatherers, t.metricsRegistry)
+	}
+	ts.lock.RUnlock()
+	return gatherers.Gather()
+}
+
+func (ts *targets) probe(name string) *prometheus.Registry {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+	if name == "" {
+		return nil
+	}
+	t := ts.byName[name]
+	if t == nil {
+		return nil
+	}
+	return t.probeRegistry
+}
+
+// collector returns the collector of the target. The name can be omitted if there is only one target.
+func (ts *targets) collector(name string) *collector.Collector {
+	ts.lock.RLock()
+	defer ts.lock.RUnlock()
+	if name == "" && len(ts.byName) == 1 {
+		for _, t := range ts.byName {
+			return t.collector
+		}
+	}
+	t := ts.byName[name]
+	if t == nil {
+		return nil
+	}
+	return t.collector
+}
+
+func closeAll(collectors []*collector.Collector) {
+	for _, c := range collectors {
+		_ = c.Close()
+	}
+}